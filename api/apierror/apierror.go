@@ -0,0 +1,132 @@
+package apierror
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+// Codes identify the kind of an Error. They are part of the API and must
+// not change once published.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeImageMissing         = "image_missing"
+	CodeInvalidParameter     = "invalid_parameter"
+	CodeNotFound             = "not_found"
+	CodeNotAcceptable        = "not_acceptable"
+	CodeImageTooLarge        = "image_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeDecodeFailed         = "decode_failed"
+	CodeFilterFailed         = "filter_failed"
+	CodeInternal             = "internal"
+	CodeBusy                 = "busy"
+	CodeCanceled             = "canceled"
+	CodeDeadlineExceeded     = "deadline_exceeded"
+)
+
+// RequestIDKey is the context key the ID of a request is stored under.
+const RequestIDKey = "request_id"
+
+// ProblemMediaType is the media type of RFC 7807 problem details, which
+// clients opt into through the Accept header.
+const ProblemMediaType = "application/problem+json"
+
+// Error is an error reported to API clients.
+type Error struct {
+	// Status is the HTTP status of the response.
+	Status int
+	// Code is one of the Code constants.
+	Code string
+	// Message describes the error to humans.
+	Message string
+	// Reason optionally refines Code, e.g. with the limit an image exceeds.
+	Reason string
+	// RetryAfter, in seconds, is sent when the request may be retried.
+	RetryAfter int
+
+	cause error
+}
+
+// New returns an Error with the given status, code and message.
+func New(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap returns an Error whose message is that of err, which it wraps.
+func Wrap(status int, code string, err error) *Error {
+	return &Error{Status: status, Code: code, Message: err.Error(), cause: err}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// WithReason returns a copy of e refined by reason.
+func (e *Error) WithReason(reason string) *Error {
+	copy := *e
+	copy.Reason = reason
+	return &copy
+}
+
+// WithRetryAfter returns a copy of e telling clients to retry after the
+// given number of seconds.
+func (e *Error) WithRetryAfter(seconds int) *Error {
+	copy := *e
+	copy.RetryAfter = seconds
+	return &copy
+}
+
+// Abort responds to the request with e and stops the handler chain. The
+// body is a problem details document if the client accepts one, and a
+// JSON object with the code, message and request ID otherwise.
+func Abort(c *gin.Context, e *Error) {
+	_ = c.Error(e)
+	if e.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(e.RetryAfter))
+	}
+
+	body := gin.H{
+		"code":       e.Code,
+		"request_id": c.GetString(RequestIDKey),
+	}
+	if e.Reason != "" {
+		body["reason"] = e.Reason
+	}
+
+	if acceptsProblem(c.GetHeader("Accept")) {
+		body["type"] = "about:blank"
+		body["title"] = http.StatusText(e.Status)
+		body["status"] = e.Status
+		body["detail"] = e.Message
+		body["instance"] = c.Request.URL.Path
+		c.Header("Content-Type", ProblemMediaType)
+	} else {
+		body["message"] = e.Message
+	}
+
+	c.Render(e.Status, render.JSON{Data: body})
+	c.Abort()
+}
+
+// acceptsProblem reports whether an Accept header lists problem details.
+func acceptsProblem(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || mediaType != ProblemMediaType {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q <= 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(t *testing.T, e *Error, accept string) (*httptest.ResponseRecorder, map[string]any) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/images/cat", func(c *gin.Context) {
+		c.Set(RequestIDKey, "abc")
+		Abort(c, e)
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/images/cat", nil)
+	req.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return w, body
+}
+
+func TestAbort_JSON(t *testing.T) {
+	e := New(http.StatusRequestEntityTooLarge, CodeImageTooLarge, "too large").WithReason("width_exceeded")
+	w, body := serve(t, e, "image/png")
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, map[string]any{
+		"code":       "image_too_large",
+		"message":    "too large",
+		"reason":     "width_exceeded",
+		"request_id": "abc",
+	}, body)
+}
+
+func TestAbort_Problem(t *testing.T) {
+	e := Wrap(http.StatusServiceUnavailable, CodeBusy, errors.New("busy")).WithRetryAfter(3)
+	w, body := serve(t, e, "image/png, application/problem+json;q=0.5")
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, ProblemMediaType, w.Header().Get("Content-Type"))
+	assert.Equal(t, "3", w.Header().Get("Retry-After"))
+	assert.Equal(t, map[string]any{
+		"type":       "about:blank",
+		"title":      "Service Unavailable",
+		"status":     float64(http.StatusServiceUnavailable),
+		"detail":     "busy",
+		"instance":   "/images/cat",
+		"code":       "busy",
+		"request_id": "abc",
+	}, body)
+}
+
+func TestAcceptsProblem(t *testing.T) {
+	assert.True(t, acceptsProblem("application/problem+json"))
+	assert.False(t, acceptsProblem("application/problem+json;q=0"))
+	assert.False(t, acceptsProblem("application/json, */*"))
+	assert.False(t, acceptsProblem(""))
+}
+
+func TestError_Unwrap(t *testing.T) {
+	cause := errors.New("cause")
+	assert.ErrorIs(t, Wrap(http.StatusBadRequest, CodeInvalidParameter, cause), cause)
+}
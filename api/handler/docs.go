@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/drew138/graphics-api/api/openapi"
+)
+
+type Docs struct {
+	spec *openapi.Document
+}
+
+func NewDocs(spec *openapi.Document) *Docs {
+	return &Docs{spec}
+}
+
+// GetSpec serves the OpenAPI document of the API.
+func (d *Docs) GetSpec() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, d.spec)
+	}
+}
+
+// GetUI serves a Swagger UI page to browse the OpenAPI document.
+func (d *Docs) GetUI() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.UI)
+	}
+}
+
+// GetAsset serves the Swagger UI asset named by the file parameter.
+func (d *Docs) GetAsset() gin.HandlerFunc {
+	assets := http.FS(openapi.Assets)
+	return func(c *gin.Context) {
+		c.FileFromFS(c.Param("file"), assets)
+	}
+}
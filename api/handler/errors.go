@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/drew138/graphics-api/api/apierror"
+	"github.com/drew138/graphics-api/internal/admission"
+	"github.com/drew138/graphics-api/internal/image"
+)
+
+// parameterErrors are the errors of the image service caused by invalid
+// request parameters rather than by a failure of the filters.
+var parameterErrors = []error{
+	image.ErrUnknownOperation,
+	image.ErrInvalidArgument,
+	image.ErrInvalidQuality,
+	image.ErrInvalidCompression,
+	image.ErrInvalidColors,
+	image.ErrInvalidDither,
+	image.ErrUnsupportedFormat,
+}
+
+// serviceError responds to a failed call to the image service. Requests
+// that ran out of time or whose client went away get a 504 or a 503, so
+// that they can be told apart from failures of the filters themselves.
+// Pipelines that could not reserve the pixels of their larger images get a
+// 503 with a Retry-After, and invalid parameters, such as disabled
+// operations, get a 400.
+func serviceError(c *gin.Context, err error, message string) {
+	switch {
+	case contextError(c, err):
+	case errors.Is(err, admission.ErrBudgetExhausted):
+		apierror.Abort(c, apierror.Wrap(http.StatusServiceUnavailable, apierror.CodeBusy, err).WithRetryAfter(admission.RetryAfter))
+	case isParameterError(err):
+		invalidParameter(c, err)
+	default:
+		apierror.Abort(c, apierror.New(http.StatusInternalServerError, apierror.CodeFilterFailed, message))
+	}
+}
+
+// contextError responds to requests that ran out of time or whose client
+// went away, and reports whether err was caused by either.
+func contextError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		apierror.Abort(c, apierror.New(http.StatusGatewayTimeout, apierror.CodeDeadlineExceeded, "Processing deadline exceeded"))
+	case errors.Is(err, context.Canceled):
+		apierror.Abort(c, apierror.New(http.StatusServiceUnavailable, apierror.CodeCanceled, "Request was canceled"))
+	default:
+		return false
+	}
+	return true
+}
+
+func isParameterError(err error) bool {
+	for _, target := range parameterErrors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func invalidParameter(c *gin.Context, err error) {
+	apierror.Abort(c, apierror.Wrap(http.StatusBadRequest, apierror.CodeInvalidParameter, err))
+}
+
+// imageMissing responds to requests that reached an image handler without
+// going through ParseImage.
+func imageMissing(c *gin.Context) {
+	apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeImageMissing, "Image not found in request"))
+}
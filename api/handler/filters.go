@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/drew138/graphics-api/internal/image"
+)
+
+type Filters struct {
+	filters []image.Filter
+}
+
+// NewFilters returns the handler describing filters, which are the ones
+// served.
+func NewFilters(filters []image.Filter) *Filters {
+	return &Filters{filters}
+}
+
+type formats struct {
+	Input  []string `json:"input"`
+	Output []string `json:"output"`
+}
+
+type filterDescription struct {
+	image.Filter
+	Formats formats `json:"formats"`
+}
+
+// GetFilters lists the filters served along with their parameters, their
+// kernel when it is fixed and the formats they read and write, so that
+// clients can build their controls without hardcoding them.
+func (f *Filters) GetFilters() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		supported := formats{Input: image.InputFormats(), Output: image.Formats()}
+
+		descriptions := make([]filterDescription, len(f.filters))
+		for i, filter := range f.filters {
+			descriptions[i] = filterDescription{filter, supported}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"filters": descriptions})
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/drew138/graphics-api/internal/image"
+)
+
+func TestGetFilters(t *testing.T) {
+	sharpen, _ := image.LookupFilter("sharpen")
+	blur, _ := image.LookupFilter("gaussianblur")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/filters", NewFilters([]image.Filter{blur, sharpen}).GetFilters())
+	req, _ := http.NewRequest("GET", "/filters", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body struct {
+		Filters []struct {
+			Name    string        `json:"name"`
+			Params  []image.Param `json:"params"`
+			Kernel  [][]float32   `json:"kernel"`
+			Formats formats       `json:"formats"`
+		} `json:"filters"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Len(t, body.Filters, 2)
+
+	described := body.Filters[0]
+	assert.Equal(t, "gaussianblur", described.Name)
+	assert.Equal(t, "sigma", described.Params[0].Name)
+	assert.Equal(t, image.ParamNumber, described.Params[0].Type)
+	assert.Equal(t, image.MinBlurSigma, *described.Params[0].Minimum)
+	assert.Equal(t, float64(image.MaxBlurRadius), *described.Params[1].Maximum)
+	assert.Equal(t, []string{"bmp", "gif", "jpeg", "png"}, described.Formats.Input)
+	assert.Contains(t, described.Formats.Output, "png")
+
+	assert.Equal(t, "sharpen", body.Filters[1].Name)
+	assert.Equal(t, [][]float32(sharpen.Kernel), body.Filters[1].Kernel)
+	assert.Equal(t, "edge", body.Filters[1].Params[0].Name)
+	assert.Equal(t, image.EdgeClamp, body.Filters[1].Params[0].Default)
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/drew138/graphics-api/internal/image"
+)
+
+var (
+	errNotAcceptable = errors.New("Requested output format is not supported")
+	errQuality       = errors.New("quality must be an integer")
+	errColors        = errors.New("colors must be an integer")
+)
+
+type acceptRange struct {
+	mediaType string
+	quality   float64
+}
+
+// outputFormat negotiates the format the response is encoded in. An
+// explicit ?format= parameter wins over the Accept header, and without
+// either the image is returned in the format it was uploaded in so that,
+// for instance, PNG transparency survives the round trip.
+func outputFormat(c *gin.Context) (string, error) {
+	input := c.GetString("format")
+	if input == "" {
+		input = image.FormatJPEG
+	}
+
+	if requested := param(c, "format"); requested != "" {
+		format, ok := image.ParseFormat(requested)
+		if !ok {
+			return "", errNotAcceptable
+		}
+		return format, nil
+	}
+
+	accept := c.GetHeader("Accept")
+	if strings.TrimSpace(accept) == "" {
+		return input, nil
+	}
+
+	ranges, refused := parseAccept(accept)
+	for _, r := range ranges {
+		switch {
+		case r.mediaType == "*/*" || r.mediaType == "image/*":
+			// Wildcards favour the input format, then jpeg, but never a
+			// format the client refused explicitly.
+			for _, format := range append([]string{input, image.FormatJPEG}, image.Formats()...) {
+				if image.CanEncode(format) && !refused[format] {
+					return format, nil
+				}
+			}
+		default:
+			if format, ok := image.FormatFromMediaType(r.mediaType); ok {
+				return format, nil
+			}
+		}
+	}
+
+	return "", errNotAcceptable
+}
+
+// encodeOptions reads the encoder options of a request. Options that do not
+// apply to the negotiated format are ignored by the encoders.
+func encodeOptions(c *gin.Context, format string) (image.EncodeOptions, error) {
+	opts := image.EncodeOptions{
+		Format:      format,
+		Compression: strings.ToLower(param(c, "compression")),
+		Dither:      strings.ToLower(param(c, "dither")),
+	}
+
+	var err error
+	if quality := param(c, "quality"); quality != "" {
+		if opts.Quality, err = strconv.Atoi(quality); err != nil {
+			return opts, errQuality
+		}
+		// Zero selects the default, so it can only be asked for implicitly.
+		if opts.Quality == 0 {
+			return opts, image.ErrInvalidQuality
+		}
+	}
+	if colors := param(c, "colors"); colors != "" {
+		if opts.Colors, err = strconv.Atoi(colors); err != nil {
+			return opts, errColors
+		}
+		if opts.Colors == 0 {
+			return opts, image.ErrInvalidColors
+		}
+	}
+
+	return opts, opts.Validate()
+}
+
+// parseAccept returns the acceptable media ranges of an Accept header
+// ordered by decreasing preference, along with the formats refused with
+// q=0, which wildcards must not resolve to.
+func parseAccept(header string) ([]acceptRange, map[string]bool) {
+	var ranges []acceptRange
+	refused := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := params["q"]; ok {
+			if quality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if quality <= 0 {
+			if format, ok := image.FormatFromMediaType(mediaType); ok {
+				refused[format] = true
+			}
+			continue
+		}
+
+		ranges = append(ranges, acceptRange{mediaType: mediaType, quality: quality})
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+	return ranges, refused
+}
+
+func writeImage(c *gin.Context, format string, bytes []byte) {
+	contentType := image.MIMEType(format)
+	c.Header("Vary", "Accept")
+	c.Header("Content-Type", contentType)
+	c.Data(http.StatusOK, contentType, bytes)
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"bytes"
+	imagePkg "image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/drew138/graphics-api/internal/image"
+	"github.com/drew138/graphics-api/mocks"
+)
+
+func TestOutputFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		target   string
+		accept   string
+		expected string
+		err      error
+	}{
+		{target: "/", expected: "png"},
+		{target: "/", accept: "*/*", expected: "png"},
+		{target: "/", accept: "image/webp, image/jpeg;q=0.8", expected: "jpeg"},
+		{target: "/", accept: "image/gif;q=0.5, image/bmp", expected: "bmp"},
+		{target: "/", accept: "image/png;q=0, image/*;q=0.1", expected: "jpeg"},
+		{target: "/", accept: "image/png;q=0, image/jpeg;q=0, */*", expected: "bmp"},
+		{target: "/", accept: "image/gif;q=0, image/*", expected: "png"},
+		{target: "/", accept: "image/bmp;q=0, image/gif;q=0, image/jpeg;q=0, image/png;q=0, */*", err: errNotAcceptable},
+		{target: "/", accept: "image/jpg", expected: "jpeg"},
+		{target: "/", accept: "image/webp", err: errNotAcceptable},
+		{target: "/", accept: "application/json", err: errNotAcceptable},
+		{target: "/?format=JPG", accept: "image/png", expected: "jpeg"},
+		{target: "/?format=gif", expected: "gif"},
+		{target: "/?format=webp", err: errNotAcceptable},
+	}
+
+	for _, tc := range cases {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("POST", tc.target, nil)
+		c.Request.Header.Set("Accept", tc.accept)
+		c.Set("format", "png")
+
+		format, err := outputFormat(c)
+
+		assert.Equal(t, tc.err, err, tc.target+" "+tc.accept)
+		assert.Equal(t, tc.expected, format, tc.target+" "+tc.accept)
+	}
+}
+
+func TestApplyFilterHandler_NotAcceptable(t *testing.T) {
+	mockService := mocks.NewService(t)
+	r := filterRouter(t, mockService, "sharpen")
+
+	// Prepare a sample image
+	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100))
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, img)
+
+	req, _ := http.NewRequest("POST", "/filter", bytes.NewReader(buf.Bytes()))
+	req.Header.Set("Content-Type", "image/png")
+	req.Header.Set("Accept", "image/webp")
+
+	// Perform the request
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	mockService.AssertNotCalled(t, "ApplyPipeline", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApplyFilterHandler_FormatParameter(t *testing.T) {
+	mockService := mocks.NewService(t)
+	r := filterRouter(t, mockService, "sharpen")
+
+	// Prepare a sample image
+	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100))
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, img)
+
+	req, _ := http.NewRequest("POST", "/filter?format=bmp", bytes.NewReader(buf.Bytes()))
+	req.Header.Set("Content-Type", "image/png")
+
+	// Mock service behavior
+	mockService.On("ApplyPipeline", mock.Anything, mock.Anything, mock.Anything, image.EncodeOptions{Format: "bmp"}).Return(buf.Bytes(), nil).Once()
+
+	// Perform the request
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/bmp", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+}
+
+func TestEncodeOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request, _ = http.NewRequest("POST", "/?quality=90&compression=BEST&colors=16&dither=none", nil)
+
+	opts, err := encodeOptions(c, "jpeg")
+
+	assert.NoError(t, err)
+	assert.Equal(t, image.EncodeOptions{Format: "jpeg", Quality: 90, Compression: "best", Colors: 16, Dither: "none"}, opts)
+
+	for target, expected := range map[string]error{
+		"/?quality=high":  errQuality,
+		"/?quality=0":     image.ErrInvalidQuality,
+		"/?colors=1":      image.ErrInvalidColors,
+		"/?colors=x":      errColors,
+		"/?compression=9": image.ErrInvalidCompression,
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("POST", target, nil)
+
+		_, err := encodeOptions(c, "jpeg")
+
+		assert.Equal(t, expected, err, target)
+	}
+}
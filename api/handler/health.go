@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/drew138/graphics-api/internal/image"
+	"github.com/drew138/graphics-api/internal/server"
+	"github.com/drew138/graphics-api/internal/source"
+)
+
+// readinessTimeout bounds the time spent checking the dependencies of the
+// server, so that a hung store fails the check instead of blocking it.
+const readinessTimeout = 2 * time.Second
+
+// graphicsModule is the module providing the filter kernels, whose version
+// is reported by /version.
+const graphicsModule = "github.com/drew138/go-graphics"
+
+type Health struct {
+	readiness *server.Readiness
+	pool      *image.Pool
+	store     source.Store
+}
+
+func NewHealth(readiness *server.Readiness, pool *image.Pool, store source.Store) *Health {
+	return &Health{readiness, pool, store}
+}
+
+// GetLiveness reports that the process is up and serving requests.
+func (h *Health) GetLiveness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// GetReadiness tells load balancers whether to send new requests to the
+// server, which is not the case while it is shutting down, while its
+// worker pool is saturated or while its source images cannot be reached.
+func (h *Health) GetReadiness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ready := true
+		checks := gin.H{}
+		fail := func(name, reason string) {
+			ready = false
+			checks[name] = reason
+		}
+
+		checks["shutdown"] = "ok"
+		if !h.readiness.Ready() {
+			fail("shutdown", "shutting down")
+		}
+
+		stats := h.pool.Stats()
+		checks["pool"] = "ok"
+		if stats.Saturated() {
+			fail("pool", "saturated")
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+		checks["storage"] = "ok"
+		// The error is logged rather than exposed, since it may name the
+		// paths or hosts of the store.
+		if err := h.store.Ping(ctx); err != nil {
+			_ = c.Error(err)
+			fail("storage", "unreachable")
+		}
+
+		status, code := "ready", http.StatusOK
+		if !ready {
+			status, code = "not ready", http.StatusServiceUnavailable
+		}
+		c.JSON(code, gin.H{"status": status, "checks": checks, "pool": stats})
+	}
+}
+
+// GetVersion reports the version of the server and of the filters it was
+// built with, as recorded in the binary by the Go toolchain.
+func (h *Health) GetVersion() gin.HandlerFunc {
+	version := buildVersion()
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, version)
+	}
+}
+
+func buildVersion() gin.H {
+	version := gin.H{}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return version
+	}
+
+	version["version"] = info.Main.Version
+	version["go_version"] = info.GoVersion
+	for _, setting := range info.Settings {
+		if name, ok := strings.CutPrefix(setting.Key, "vcs."); ok {
+			version[name] = setting.Value
+		}
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == graphicsModule {
+			version["go_graphics_version"] = dep.Version
+		}
+	}
+	return version
+}
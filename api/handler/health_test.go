@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/drew138/graphics-api/internal/image"
+	"github.com/drew138/graphics-api/internal/server"
+	"github.com/drew138/graphics-api/internal/source"
+)
+
+type unreachableStore struct{}
+
+func (unreachableStore) Open(context.Context, string) (io.ReadCloser, error) {
+	return nil, errors.New("unreachable")
+}
+
+func (unreachableStore) Ping(context.Context) error {
+	return errors.New("dial tcp 10.0.0.1:443: i/o timeout")
+}
+
+func setupHealth(store source.Store) *gin.Engine {
+	health := NewHealth(&server.Readiness{}, image.NewPool(1), store)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/healthz", health.GetLiveness())
+	r.GET("/readyz", health.GetReadiness())
+	r.GET("/version", health.GetVersion())
+	return r
+}
+
+func TestGetLivenessHandler(t *testing.T) {
+	r := setupHealth(unreachableStore{})
+
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGetReadinessHandler(t *testing.T) {
+	cases := []struct {
+		store   source.Store
+		status  int
+		storage string
+	}{
+		{source.NewDir(t.TempDir()), http.StatusOK, "ok"},
+		{unreachableStore{}, http.StatusServiceUnavailable, "unreachable"},
+		{source.NewDir("missing"), http.StatusServiceUnavailable, "unreachable"},
+	}
+
+	for _, tc := range cases {
+		r := setupHealth(tc.store)
+
+		req, _ := http.NewRequest("GET", "/readyz", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, tc.status, w.Code)
+
+		var body struct {
+			Checks map[string]string `json:"checks"`
+			Pool   image.PoolStats   `json:"pool"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "ok", body.Checks["shutdown"])
+		assert.Equal(t, "ok", body.Checks["pool"])
+		assert.Equal(t, tc.storage, body.Checks["storage"])
+		assert.Equal(t, 1, body.Pool.Workers)
+	}
+}
+
+func TestGetVersionHandler(t *testing.T) {
+	r := setupHealth(unreachableStore{})
+
+	req, _ := http.NewRequest("GET", "/version", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var body map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.NotEmpty(t, body["go_version"])
+	assert.NotEmpty(t, body["go_graphics_version"])
+}
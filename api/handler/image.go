@@ -1,12 +1,13 @@
 package handler
 
 import (
+	"fmt"
 	imagePkg "image"
 	"net/http"
 
-	"github.com/drew138/go-graphics/filters/kernels"
 	"github.com/gin-gonic/gin"
 
+	"github.com/drew138/graphics-api/api/apierror"
 	"github.com/drew138/graphics-api/internal/image"
 )
 
@@ -18,82 +19,102 @@ func NewImage(service image.Service) *Image {
 	return &Image{service}
 }
 
-func (s *Image) CreateSharpen() gin.HandlerFunc {
+// ApplyFilter returns the handler of the endpoint of filter. Its arguments
+// are read from the parameters of the request named after them, or from
+// their header.
+func (s *Image) ApplyFilter(filter image.Filter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		image, exists := c.Get("image")
+		img, exists := c.Get("image")
 		if !exists {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Image not found in request"})
+			imageMissing(c)
 			return
 		}
 
-		bytes, err := s.service.TransformImage(image.(imagePkg.Image), kernels.Sharpen)
+		format, err := outputFormat(c)
+		if err != nil {
+			apierror.Abort(c, apierror.Wrap(http.StatusNotAcceptable, apierror.CodeNotAcceptable, err))
+			return
+		}
 
+		opts, err := encodeOptions(c, format)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sharpen image"})
+			invalidParameter(c, err)
 			return
 		}
 
-		c.Header("Content-Type", "image/jpeg")
-		c.Data(http.StatusOK, "image/jpeg", bytes)
-	}
-}
+		args := map[string]string{}
+		for _, p := range filter.Params {
+			value := param(c, p.Name)
+			if value == "" && p.Header != "" {
+				value = c.GetHeader(p.Header)
+			}
+			if value != "" {
+				args[p.Name] = value
+			} else if p.Required {
+				apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, fmt.Sprintf("Parameter %s not found in request", p.Name)))
+				return
+			}
+		}
 
-func (s *Image) CreateEdgeDetection() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		image, exists := c.Get("image")
-		if !exists {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Image not found in request"})
+		ops := []image.Operation{{Name: filter.Name, Args: args}}
+		if err := image.ValidateOperations(ops); err != nil {
+			invalidParameter(c, err)
 			return
 		}
 
-		bytes, err := s.service.TransformImage(image.(imagePkg.Image), kernels.EdgeDetection)
+		bytes, err := s.service.ApplyPipeline(c.Request.Context(), img.(imagePkg.Image), ops, opts)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sharpen image"})
+			serviceError(c, err, fmt.Sprintf("Failed to apply %s", filter.Name))
 			return
 		}
 
-		c.Header("Content-Type", "image/jpeg")
-		c.Data(http.StatusOK, "image/jpeg", bytes)
+		writeImage(c, format, bytes)
 	}
 }
 
-func (s *Image) CreateGaussianBlur() gin.HandlerFunc {
+func (s *Image) CreatePipeline() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		image, exists := c.Get("image")
+		img, exists := c.Get("image")
 		if !exists {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Image not found in request"})
+			imageMissing(c)
 			return
 		}
 
-		bytes, err := s.service.TransformImage(image.(imagePkg.Image), kernels.GaussianBlur)
+		format, err := outputFormat(c)
+		if err != nil {
+			apierror.Abort(c, apierror.Wrap(http.StatusNotAcceptable, apierror.CodeNotAcceptable, err))
+			return
+		}
 
+		opts, err := encodeOptions(c, format)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sharpen image"})
+			invalidParameter(c, err)
 			return
 		}
 
-		c.Header("Content-Type", "image/jpeg")
-		c.Data(http.StatusOK, "image/jpeg", bytes)
-	}
-}
+		raw := param(c, "operations")
+		if raw == "" {
+			raw = c.GetHeader("X-Operations")
+		}
+		if raw == "" {
+			apierror.Abort(c, apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, "Operations not found in request"))
+			return
+		}
 
-func (s *Image) CreateBoxBlur() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		image, exists := c.Get("image")
-		if !exists {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Image not found in request"})
+		ops, err := image.ParseOperations(raw)
+		if err != nil {
+			invalidParameter(c, err)
 			return
 		}
 
-		bytes, err := s.service.TransformImage(image.(imagePkg.Image), kernels.BoxBlur)
+		bytes, err := s.service.ApplyPipeline(c.Request.Context(), img.(imagePkg.Image), ops, opts)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sharpen image"})
+			serviceError(c, err, "Failed to apply pipeline")
 			return
 		}
 
-		c.Header("Content-Type", "image/jpeg")
-		c.Data(http.StatusOK, "image/jpeg", bytes)
+		writeImage(c, format, bytes)
 	}
 }
@@ -2,12 +2,16 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"errors"
-	"fmt"
 	imagePkg "image"
 	"image/jpeg"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -15,50 +19,75 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"github.com/drew138/graphics-api/api/middleware"
+	"github.com/drew138/graphics-api/internal/image"
 	"github.com/drew138/graphics-api/mocks"
 )
 
-func TestCreateSharpenHandler(t *testing.T) {
-	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateSharpen()
-
-	// Prepare a sample image
-	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100))
-	buf := new(bytes.Buffer)
-	_ = jpeg.Encode(buf, img, nil)
+// filterRouter serves the handler of the filter called name at /filter.
+func filterRouter(t *testing.T, service image.Service, name string) *gin.Engine {
+	filter, ok := image.LookupFilter(name)
+	if !ok {
+		t.Fatalf("filter %s is not registered", name)
+	}
 
-	// Set up Gin context
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 	r.Use(middleware.ParseImage())
-	r.GET("/sharpen", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/sharpen", bytes.NewReader(buf.Bytes()))
-	req.Header.Set("Content-Type", "image/jpeg")
-	req.Header.Set("Content-Length", fmt.Sprint(buf.Len()))
-
-	// Mock service behavior
-	mockService.On("TransformImage", mock.Anything, mock.Anything).Return(buf.Bytes(), nil).Once()
+	r.POST("/filter", NewImage(service).ApplyFilter(filter))
+	return r
+}
 
-	// Perform the request
-	w := httptest.NewRecorder()
-	r.ServeHTTP(w, req)
+func jpegBody() []byte {
+	buf := new(bytes.Buffer)
+	_ = jpeg.Encode(buf, imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100)), nil)
+	return buf.Bytes()
+}
 
-	// Assertions
-	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
+func TestApplyFilterHandler(t *testing.T) {
+	body := jpegBody()
+
+	for _, filter := range image.Filters() {
+		mockService := mocks.NewService(t)
+		r := filterRouter(t, mockService, filter.Name)
+
+		args := map[string]string{}
+		req, _ := http.NewRequest("POST", "/filter", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "image/jpeg")
+		switch filter.Name {
+		case "custom":
+			args["kernel"] = "[[0,0,0],[0,1,0],[0,0,0]]"
+			req.Header.Set("X-Kernel", args["kernel"])
+		case "resize", "crop":
+			args["width"] = "10"
+			req.URL.RawQuery = "width=10"
+		case "rotate":
+			args["angle"] = "90"
+			req.URL.RawQuery = "angle=90"
+		}
+
+		// Mock service behavior
+		ops := []image.Operation{{Name: filter.Name, Args: args}}
+		mockService.On("ApplyPipeline", mock.Anything, mock.Anything, ops, image.EncodeOptions{Format: "jpeg"}).Return(body, nil).Once()
+
+		// Perform the request
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusOK, w.Code, filter.Name)
+		assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"), filter.Name)
+	}
 }
 
-func TestCreateSharpenHandler_ImageNotFound(t *testing.T) {
+func TestApplyFilterHandler_ImageNotFound(t *testing.T) {
 	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateSharpen()
+	filter, _ := image.LookupFilter("sharpen")
 
-	// Set up Gin context
+	// Set up Gin context without ParseImage
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	r.GET("/sharpen", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/sharpen", nil)
+	r.POST("/sharpen", NewImage(mockService).ApplyFilter(filter))
+	req, _ := http.NewRequest("POST", "/sharpen", nil)
 
 	// Perform the request
 	w := httptest.NewRecorder()
@@ -66,30 +95,17 @@ func TestCreateSharpenHandler_ImageNotFound(t *testing.T) {
 
 	// Assertions
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
+	assert.Contains(t, w.Body.String(), `"code":"image_missing"`)
 }
 
-func TestCreateSharpenHandler_FailedToSharpen(t *testing.T) {
+func TestApplyFilterHandler_Failed(t *testing.T) {
 	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateSharpen()
-
-	// Prepare a sample image
-	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100))
-	buf := new(bytes.Buffer)
-	_ = jpeg.Encode(buf, img, nil)
-
-	// Set up Gin context
-	gin.SetMode(gin.TestMode)
-	r := gin.New()
-	r.Use(middleware.ParseImage())
-	r.GET("/sharpen", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/sharpen", bytes.NewReader(buf.Bytes()))
+	r := filterRouter(t, mockService, "sharpen")
+	req, _ := http.NewRequest("POST", "/filter", bytes.NewReader(jpegBody()))
 	req.Header.Set("Content-Type", "image/jpeg")
-	req.Header.Set("Content-Length", fmt.Sprint(buf.Len()))
 
 	// Mock service behavior to simulate error
-	mockService.On("TransformImage", mock.Anything, mock.Anything).
+	mockService.On("ApplyPipeline", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
 		Return(nil, errors.New("failed to sharpen")).Once()
 
 	// Perform the request
@@ -98,30 +114,22 @@ func TestCreateSharpenHandler_FailedToSharpen(t *testing.T) {
 
 	// Assertions
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
+	assert.Contains(t, w.Body.String(), `"code":"filter_failed"`)
+	assert.Contains(t, w.Body.String(), "Failed to apply sharpen")
 }
 
-func TestCreateGaussianBlurHandler(t *testing.T) {
+func TestApplyFilterHandler_PreservesInputFormat(t *testing.T) {
 	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateGaussianBlur()
+	r := filterRouter(t, mockService, "sharpen")
 
 	// Prepare a sample image
-	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100))
 	buf := new(bytes.Buffer)
-	_ = jpeg.Encode(buf, img, nil)
-
-	// Set up Gin context
-	gin.SetMode(gin.TestMode)
-	r := gin.New()
-	r.Use(middleware.ParseImage())
-	r.GET("/gaussian-blur", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/gaussian-blur", bytes.NewReader(buf.Bytes()))
-	req.Header.Set("Content-Type", "image/jpeg")
-	req.Header.Set("Content-Length", fmt.Sprint(buf.Len()))
+	_ = png.Encode(buf, imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100)))
+	req, _ := http.NewRequest("POST", "/filter", bytes.NewReader(buf.Bytes()))
+	req.Header.Set("Content-Type", "image/png")
 
 	// Mock service behavior
-	mockService.On("TransformImage", mock.Anything, mock.Anything).Return(buf.Bytes(), nil).Once()
+	mockService.On("ApplyPipeline", mock.Anything, mock.Anything, mock.Anything, image.EncodeOptions{Format: "png"}).Return(buf.Bytes(), nil).Once()
 
 	// Perform the request
 	w := httptest.NewRecorder()
@@ -129,20 +137,14 @@ func TestCreateGaussianBlurHandler(t *testing.T) {
 
 	// Assertions
 	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
 }
 
-func TestCreateGaussianBlur_ImageNotFound(t *testing.T) {
+func TestApplyFilterHandler_RequiredParameter(t *testing.T) {
 	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateGaussianBlur()
-
-	// Set up Gin context
-	gin.SetMode(gin.TestMode)
-	r := gin.New()
-	r.GET("/gaussian-blur", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/gaussian-blur", nil)
+	r := filterRouter(t, mockService, "custom")
+	req, _ := http.NewRequest("POST", "/filter", bytes.NewReader(jpegBody()))
+	req.Header.Set("Content-Type", "image/jpeg")
 
 	// Perform the request
 	w := httptest.NewRecorder()
@@ -150,62 +152,76 @@ func TestCreateGaussianBlur_ImageNotFound(t *testing.T) {
 
 	// Assertions
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
+	assert.Contains(t, w.Body.String(), "Parameter kernel not found in request")
 }
 
-func TestCreateGaussianBlur_FailedToSharpen(t *testing.T) {
-	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateGaussianBlur()
-
-	// Prepare a sample image
-	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100))
-	buf := new(bytes.Buffer)
-	_ = jpeg.Encode(buf, img, nil)
+func TestApplyFilterHandler_InvalidParameters(t *testing.T) {
+	cases := map[string]string{
+		"custom?kernel=" + url.QueryEscape("[[1,2],[3,4]]"): "square matrix",
+		"gaussianblur?radius=500":                           "radius must be an integer",
+	}
+
+	for path, message := range cases {
+		mockService := mocks.NewService(t)
+		name, query, _ := strings.Cut(path, "?")
+		r := filterRouter(t, mockService, name)
+		req, _ := http.NewRequest("POST", "/filter?"+query, bytes.NewReader(jpegBody()))
+		req.Header.Set("Content-Type", "image/jpeg")
+
+		// Perform the request
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		// Assertions
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_parameter"`, path)
+		assert.Contains(t, w.Body.String(), message, path)
+	}
+}
 
-	// Set up Gin context
-	gin.SetMode(gin.TestMode)
-	r := gin.New()
-	r.Use(middleware.ParseImage())
-	r.GET("/gaussian-blur", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/gaussian-blur", bytes.NewReader(buf.Bytes()))
-	req.Header.Set("Content-Type", "image/jpeg")
-	req.Header.Set("Content-Length", fmt.Sprint(buf.Len()))
+func TestApplyFilterHandler_Parameters(t *testing.T) {
+	mockService := mocks.NewService(t)
+	r := filterRouter(t, mockService, "gaussianblur")
+	body := jpegBody()
 
-	// Mock service behavior to simulate error
-	mockService.On("TransformImage", mock.Anything, mock.Anything).
-		Return(nil, errors.New("failed to sharpen")).Once()
+	// Mock service behavior
+	ops := []image.Operation{{Name: "gaussianblur", Args: map[string]string{"sigma": "2.5", "radius": "8"}}}
+	mockService.On("ApplyPipeline", mock.Anything, mock.Anything, ops, image.EncodeOptions{Format: "jpeg"}).Return(body, nil).Once()
 
 	// Perform the request
+	req, _ := http.NewRequest("POST", "/filter?sigma=2.5&radius=8", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "image/jpeg")
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
 	// Assertions
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
+	assert.Equal(t, http.StatusOK, w.Code)
 }
 
-func TestCreateEdgeDetectionHandler(t *testing.T) {
+func TestApplyFilterHandler_MultipartForm(t *testing.T) {
 	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateEdgeDetection()
+	r := filterRouter(t, mockService, "custom")
 
-	// Prepare a sample image
-	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100))
+	// Prepare a sample image in a multipart form
 	buf := new(bytes.Buffer)
-	_ = jpeg.Encode(buf, img, nil)
+	_ = png.Encode(buf, imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100)))
 
-	// Set up Gin context
-	gin.SetMode(gin.TestMode)
-	r := gin.New()
-	r.Use(middleware.ParseImage())
-	r.GET("/edge-detection", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/edge-detection", bytes.NewReader(buf.Bytes()))
-	req.Header.Set("Content-Type", "image/jpeg")
-	req.Header.Set("Content-Length", fmt.Sprint(buf.Len()))
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("kernel", "[[0,0,0],[0,1,0],[0,0,0]]")
+	_ = writer.WriteField("quality", "90")
+	_ = writer.WriteField("format", "jpeg")
+	part, _ := writer.CreateFormFile("image", "image.png")
+	_, _ = part.Write(buf.Bytes())
+	_ = writer.Close()
+
+	req, _ := http.NewRequest("POST", "/filter", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
 
 	// Mock service behavior
-	mockService.On("TransformImage", mock.Anything, mock.Anything).Return(buf.Bytes(), nil).Once()
+	ops := []image.Operation{{Name: "custom", Args: map[string]string{"kernel": "[[0,0,0],[0,1,0],[0,0,0]]"}}}
+	mockService.On("ApplyPipeline", mock.Anything, mock.Anything, ops, image.EncodeOptions{Format: "jpeg", Quality: 90}).
+		Return(buf.Bytes(), nil).Once()
 
 	// Perform the request
 	w := httptest.NewRecorder()
@@ -214,33 +230,11 @@ func TestCreateEdgeDetectionHandler(t *testing.T) {
 	// Assertions
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
-}
-
-func TestCreateEdgeDetection_ImageNotFound(t *testing.T) {
-	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateEdgeDetection()
-
-	// Set up Gin context
-	gin.SetMode(gin.TestMode)
-	r := gin.New()
-	r.GET("/edge-detection", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/edge-detection", nil)
-
-	// Perform the request
-	w := httptest.NewRecorder()
-	r.ServeHTTP(w, req)
-
-	// Assertions
-	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
 }
 
-func TestCreateEdgeDetection_FailedToSharpen(t *testing.T) {
+func TestCreatePipelineHandler(t *testing.T) {
 	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateEdgeDetection()
+	pipelineHandler := NewImage(mockService).CreatePipeline()
 
 	// Prepare a sample image
 	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100))
@@ -251,28 +245,27 @@ func TestCreateEdgeDetection_FailedToSharpen(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 	r.Use(middleware.ParseImage())
-	r.GET("/edge-detection", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/edge-detection", bytes.NewReader(buf.Bytes()))
+	r.POST("/pipeline", pipelineHandler)
+	req, _ := http.NewRequest("POST", "/pipeline", bytes.NewReader(buf.Bytes()))
 	req.Header.Set("Content-Type", "image/jpeg")
-	req.Header.Set("Content-Length", fmt.Sprint(buf.Len()))
+	req.Header.Set("X-Operations", `[{"op": "boxblur"}, {"op": "sharpen"}]`)
 
-	// Mock service behavior to simulate error
-	mockService.On("TransformImage", mock.Anything, mock.Anything).
-		Return(nil, errors.New("failed to sharpen")).Once()
+	// Mock service behavior
+	ops := []image.Operation{{Name: "boxblur", Args: map[string]string{}}, {Name: "sharpen", Args: map[string]string{}}}
+	mockService.On("ApplyPipeline", mock.Anything, mock.Anything, ops, image.EncodeOptions{Format: "jpeg"}).Return(buf.Bytes(), nil).Once()
 
 	// Perform the request
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
 	// Assertions
-	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
 }
 
-func TestCreateBoxBlurHandler(t *testing.T) {
+func TestCreatePipelineHandler_InvalidOperations(t *testing.T) {
 	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateBoxBlur()
+	pipelineHandler := NewImage(mockService).CreatePipeline()
 
 	// Prepare a sample image
 	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100))
@@ -283,34 +276,9 @@ func TestCreateBoxBlurHandler(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 	r.Use(middleware.ParseImage())
-	r.GET("/box-blur", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/box-blur", bytes.NewReader(buf.Bytes()))
+	r.POST("/pipeline", pipelineHandler)
+	req, _ := http.NewRequest("POST", "/pipeline?operations="+url.QueryEscape(`[{"op": "emboss"}]`), bytes.NewReader(buf.Bytes()))
 	req.Header.Set("Content-Type", "image/jpeg")
-	req.Header.Set("Content-Length", fmt.Sprint(buf.Len()))
-
-	// Mock service behavior
-	mockService.On("TransformImage", mock.Anything, mock.Anything).Return(buf.Bytes(), nil).Once()
-
-	// Perform the request
-	w := httptest.NewRecorder()
-	r.ServeHTTP(w, req)
-
-	// Assertions
-	assert.Equal(t, http.StatusOK, w.Code)
-	assert.Equal(t, "image/jpeg", w.Header().Get("Content-Type"))
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
-}
-
-func TestCreateBoxBlur_ImageNotFound(t *testing.T) {
-	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateBoxBlur()
-
-	// Set up Gin context
-	gin.SetMode(gin.TestMode)
-	r := gin.New()
-	r.GET("/box-blur", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/box-blur", nil)
 
 	// Perform the request
 	w := httptest.NewRecorder()
@@ -318,13 +286,12 @@ func TestCreateBoxBlur_ImageNotFound(t *testing.T) {
 
 	// Assertions
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
+	assert.Contains(t, w.Body.String(), "unknown operation")
 }
 
-func TestCreateBoxBlur_FailedToSharpen(t *testing.T) {
+func TestCreatePipelineHandler_FailedToApply(t *testing.T) {
 	mockService := mocks.NewService(t)
-	sharpenHandler := NewImage(mockService).CreateBoxBlur()
+	pipelineHandler := NewImage(mockService).CreatePipeline()
 
 	// Prepare a sample image
 	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 100, 100))
@@ -335,14 +302,14 @@ func TestCreateBoxBlur_FailedToSharpen(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
 	r.Use(middleware.ParseImage())
-	r.GET("/box-blur", sharpenHandler)
-	req, _ := http.NewRequest("GET", "/box-blur", bytes.NewReader(buf.Bytes()))
+	r.POST("/pipeline", pipelineHandler)
+	req, _ := http.NewRequest("POST", "/pipeline", bytes.NewReader(buf.Bytes()))
 	req.Header.Set("Content-Type", "image/jpeg")
-	req.Header.Set("Content-Length", fmt.Sprint(buf.Len()))
+	req.Header.Set("X-Operations", `[{"op": "sharpen"}]`)
 
 	// Mock service behavior to simulate error
-	mockService.On("TransformImage", mock.Anything, mock.Anything).
-		Return(nil, errors.New("failed to sharpen")).Once()
+	mockService.On("ApplyPipeline", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("failed to apply")).Once()
 
 	// Perform the request
 	w := httptest.NewRecorder()
@@ -350,6 +317,34 @@ func TestCreateBoxBlur_FailedToSharpen(t *testing.T) {
 
 	// Assertions
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
-	assert.NotNil(t, w.Body)
-	// You may add more assertions based on your handler's behavior
+}
+
+func TestServiceErrors(t *testing.T) {
+	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 10, 10))
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, img)
+
+	cases := []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{context.DeadlineExceeded, http.StatusGatewayTimeout, "deadline_exceeded"},
+		{context.Canceled, http.StatusServiceUnavailable, "canceled"},
+	}
+	for _, tc := range cases {
+		mockService := mocks.NewService(t)
+		mockService.On("ApplyPipeline", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+			Return(nil, tc.err).Once()
+
+		r := filterRouter(t, mockService, "sharpen")
+		req, _ := http.NewRequest("POST", "/filter", bytes.NewReader(buf.Bytes()))
+		req.Header.Set("Content-Type", "image/png")
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, tc.status, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"`+tc.code+`"`)
+	}
 }
@@ -0,0 +1,16 @@
+package handler
+
+import "github.com/gin-gonic/gin"
+
+// param returns a request parameter from the query string or, for
+// multipart uploads, from the form fields ParseImage extracted. The query
+// string takes precedence.
+func param(c *gin.Context, name string) string {
+	if value := c.Query(name); value != "" {
+		return value
+	}
+	if fields, ok := c.Get("fields"); ok {
+		return fields.(map[string]string)[name]
+	}
+	return ""
+}
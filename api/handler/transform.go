@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/drew138/graphics-api/api/apierror"
+	"github.com/drew138/graphics-api/internal/admission"
+	"github.com/drew138/graphics-api/internal/image"
+	"github.com/drew138/graphics-api/internal/source"
+)
+
+// DefaultTransformMaxAge is how long intermediaries may cache a transformed
+// image unless configured otherwise.
+const DefaultTransformMaxAge = 24 * time.Hour
+
+type Transform struct {
+	service image.Service
+	store   source.Store
+	limits  image.Limits
+	budget  *admission.Budget
+	maxAge  time.Duration
+}
+
+// TransformOption customizes the handler built by NewTransform.
+type TransformOption func(*Transform)
+
+// WithSourceLimits bounds the dimensions of the source images.
+func WithSourceLimits(limits image.Limits) TransformOption {
+	return func(t *Transform) {
+		t.limits = limits
+	}
+}
+
+// WithBudget makes every request reserve the pixels of its source image
+// from budget before decoding it.
+func WithBudget(budget *admission.Budget) TransformOption {
+	return func(t *Transform) {
+		t.budget = budget
+	}
+}
+
+// WithMaxAge sets how long intermediaries may cache a transformed image.
+func WithMaxAge(d time.Duration) TransformOption {
+	return func(t *Transform) {
+		t.maxAge = d
+	}
+}
+
+func NewTransform(service image.Service, store source.Store, opts ...TransformOption) *Transform {
+	t := &Transform{
+		service: service,
+		store:   store,
+		limits:  image.DefaultLimits(),
+		maxAge:  DefaultTransformMaxAge,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// CreateTransformation serves a source image transformed as described by
+// the "path" route parameter, e.g. blur:2/sharpen/format:png/<source-id>.
+// Requests for a non canonical spelling of a transformation are redirected
+// to the canonical one, so that caches store every transformation once.
+func (t *Transform) CreateTransformation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Param("path")
+		transformation, err := image.ParsePath(path)
+		if err != nil {
+			invalidParameter(c, err)
+			return
+		}
+
+		prefix := strings.TrimSuffix(c.Request.URL.Path, path)
+		canonical := prefix + "/" + transformation.String()
+		if c.Request.URL.Path != canonical {
+			location := url.URL{Path: canonical, RawQuery: c.Request.URL.RawQuery}
+			c.Redirect(http.StatusMovedPermanently, location.String())
+			return
+		}
+
+		file, err := t.store.Open(c.Request.Context(), transformation.Source)
+		switch {
+		case errors.Is(err, source.ErrNotFound):
+			apierror.Abort(c, apierror.Wrap(http.StatusNotFound, apierror.CodeNotFound, err))
+			return
+		case errors.Is(err, source.ErrInvalidID):
+			invalidParameter(c, err)
+			return
+		case err != nil:
+			if !contextError(c, err) {
+				apierror.Abort(c, apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "Failed to open source image"))
+			}
+			return
+		}
+		defer file.Close()
+
+		header, err := image.ReadHeader(file, t.limits)
+		var limitErr *image.LimitError
+		if errors.As(err, &limitErr) {
+			apierror.Abort(c, apierror.Wrap(http.StatusRequestEntityTooLarge, apierror.CodeImageTooLarge, err).WithReason(limitErr.Reason))
+			return
+		}
+		// Source images are not provided by clients, so failing to decode
+		// them is an error of the server.
+		if err != nil {
+			apierror.Abort(c, apierror.New(http.StatusInternalServerError, apierror.CodeDecodeFailed, "Failed to decode source image"))
+			return
+		}
+
+		release, err := t.budget.Acquire(c.Request.Context(), header.Pixels())
+		if errors.Is(err, admission.ErrBudgetExhausted) {
+			apierror.Abort(c, apierror.Wrap(http.StatusServiceUnavailable, apierror.CodeBusy, err).WithRetryAfter(admission.RetryAfter))
+			return
+		}
+		if err != nil {
+			contextError(c, err)
+			return
+		}
+		defer release()
+
+		img, err := header.Decode()
+		if err != nil {
+			apierror.Abort(c, apierror.New(http.StatusInternalServerError, apierror.CodeDecodeFailed, "Failed to decode source image"))
+			return
+		}
+
+		opts := transformation.Options
+		if opts.Format == "" {
+			opts.Format = header.Format
+		}
+
+		bytes, err := t.service.ApplyPipeline(c.Request.Context(), img, transformation.Operations, opts)
+		if err != nil {
+			serviceError(c, err, "Failed to transform image")
+			return
+		}
+
+		etag := fmt.Sprintf(`"%x"`, sha256.Sum256(bytes))
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(t.maxAge.Seconds())))
+		c.Header("ETag", etag)
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		contentType := image.MIMEType(opts.Format)
+		c.Data(http.StatusOK, contentType, bytes)
+	}
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	imagePkg "image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/drew138/graphics-api/internal/image"
+	"github.com/drew138/graphics-api/internal/source"
+	"github.com/drew138/graphics-api/mocks"
+)
+
+func setupTransform(t *testing.T) (*mocks.Service, *gin.Engine) {
+	// Prepare a source image on disk
+	root := t.TempDir()
+	img := imagePkg.NewRGBA(imagePkg.Rect(0, 0, 10, 10))
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, img)
+	_ = os.WriteFile(filepath.Join(root, "cat.png"), buf.Bytes(), 0o644)
+
+	// And one beyond the pixel limit
+	buf.Reset()
+	_ = png.Encode(buf, imagePkg.NewRGBA(imagePkg.Rect(0, 0, 20, 20)))
+	_ = os.WriteFile(filepath.Join(root, "big.png"), buf.Bytes(), 0o644)
+
+	mockService := mocks.NewService(t)
+	limits := image.Limits{MaxPixels: 100}
+	transformHandler := NewTransform(mockService, source.NewDir(root), WithSourceLimits(limits)).CreateTransformation()
+
+	// Set up Gin context
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/t/*path", transformHandler)
+
+	return mockService, r
+}
+
+func TestCreateTransformationHandler(t *testing.T) {
+	mockService, r := setupTransform(t)
+
+	// Mock service behavior
+	ops := []image.Operation{{Name: "sharpen", Args: map[string]string{}}}
+	mockService.On("ApplyPipeline", mock.Anything, mock.Anything, ops, image.EncodeOptions{Format: "png"}).Return([]byte("png"), nil).Once()
+
+	// Perform the request
+	req, _ := http.NewRequest("GET", "/t/sharpen/cat.png", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	// Assertions
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Cache-Control"), "public")
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	assert.Equal(t, "png", w.Body.String())
+
+	// A matching ETag results in a 304
+	mockService.On("ApplyPipeline", mock.Anything, mock.Anything, ops, image.EncodeOptions{Format: "png"}).Return([]byte("png"), nil).Once()
+	req, _ = http.NewRequest("GET", "/t/sharpen/cat.png", nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestCreateTransformationHandler_RedirectsToCanonical(t *testing.T) {
+	_, r := setupTransform(t)
+
+	req, _ := http.NewRequest("GET", "/t/format:PNG/Blur/cat.png", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/t/gaussianblur/format:png/cat.png", w.Header().Get("Location"))
+
+	// Arguments left to their default are dropped.
+	req, _ = http.NewRequest("GET", "/t/sharpen:CLAMP/cat.png", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/t/sharpen/cat.png", w.Header().Get("Location"))
+}
+
+func TestCreateTransformationHandler_Errors(t *testing.T) {
+	mockService, r := setupTransform(t)
+
+	cases := map[string]int{
+		"/t/emboss/cat.png":  http.StatusBadRequest,
+		"/t/sharpen/dog.png": http.StatusNotFound,
+		"/t/sharpen/.git":    http.StatusBadRequest,
+		"/t/sharpen/big.png": http.StatusRequestEntityTooLarge,
+	}
+	for target, status := range cases {
+		req, _ := http.NewRequest("GET", target, nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, status, w.Code, target)
+	}
+
+	// Mock service behavior to simulate error
+	mockService.On("ApplyPipeline", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(nil, errors.New("failed to transform")).Once()
+
+	req, _ := http.NewRequest("GET", "/t/sharpen/cat.png", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultProcessingDeadline bounds the time spent on a request unless
+// configured otherwise.
+const DefaultProcessingDeadline = 30 * time.Second
+
+// Deadline cancels the context of a request once d has elapsed, so that
+// the filters applied to it stop instead of running to completion. A
+// non-positive d disables the deadline.
+func Deadline(d time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
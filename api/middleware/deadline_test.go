@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeadline(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := map[time.Duration]bool{
+		time.Minute: true,
+		0:           false,
+	}
+	for d, expected := range cases {
+		router := gin.New()
+		router.Use(Deadline(d))
+		router.GET("/", func(c *gin.Context) {
+			deadline, ok := c.Request.Context().Deadline()
+			if ok != expected {
+				t.Errorf("deadline %v: expected deadline set to be %v, got %v", d, expected, ok)
+			}
+			if ok && time.Until(deadline) > d {
+				t.Errorf("deadline %v: deadline is too far in the future", d)
+			}
+			c.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+	}
+}
+
+func TestDeadline_Expires(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Deadline(time.Millisecond))
+	router.GET("/", func(c *gin.Context) {
+		<-c.Request.Context().Done()
+		if err := c.Request.Context().Err(); err != context.DeadlineExceeded {
+			t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecated marks the responses of a route deprecated since the given
+// time with the Deprecation (RFC 9745) and Sunset (RFC 8594) headers, and
+// links them to the successor route that replaces it.
+func Deprecated(since, sunset time.Time, successor string) gin.HandlerFunc {
+	deprecation := fmt.Sprintf("@%d", since.Unix())
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+	link := fmt.Sprintf(`<%s>; rel="successor-version"`, successor)
+
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunsetDate)
+		c.Header("Link", link)
+		c.Next()
+	}
+}
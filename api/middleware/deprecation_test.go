@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeprecated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	since := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 4, 1, 0, 0, 0, 0, time.UTC)
+	router.GET("/old", Deprecated(since, sunset, "/api/v1/new"), func(c *gin.Context) {
+		c.Status(http.StatusBadRequest)
+	})
+
+	req, _ := http.NewRequest("GET", "/old", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	expected := map[string]string{
+		"Deprecation": "@1790812800",
+		"Sunset":      "Thu, 01 Apr 2027 00:00:00 GMT",
+		"Link":        `</api/v1/new>; rel="successor-version"`,
+	}
+	for header, value := range expected {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("expected %s header '%s', got '%s'", header, value, got)
+		}
+	}
+}
@@ -2,41 +2,304 @@ package middleware
 
 import (
 	"bytes"
-	"image"
+	"context"
+	"errors"
 	"io"
-	"strings"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/drew138/graphics-api/api/apierror"
+	"github.com/drew138/graphics-api/internal/admission"
+	imageService "github.com/drew138/graphics-api/internal/image"
 )
 
-func ParseImage() gin.HandlerFunc {
-	return func(c *gin.Context) {
+const (
+	// DefaultMaxBodySize bounds the whole request body, leaving room for the
+	// form fields next to an image part of the maximum size.
+	DefaultMaxBodySize = 33 << 20
+	// DefaultMaxImagePartSize bounds the image part of a multipart upload.
+	DefaultMaxImagePartSize = 32 << 20
+	// DefaultMaxFieldSize bounds every other part of a multipart upload.
+	DefaultMaxFieldSize = 64 << 10
+
+	imageField = "image"
+	// autoOrientParam is the query parameter, or form field, disabling the
+	// EXIF orientation of the image.
+	autoOrientParam = "auto_orient"
+)
+
+// Reasons reported along with image_too_large errors, next to those of
+// imageService.LimitError.
+const (
+	ReasonBodyTooLarge = "body_too_large"
+	ReasonPartTooLarge = "part_too_large"
+)
+
+var (
+	errBodyTooLarge     = apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeImageTooLarge, "Request body exceeds the maximum allowed size").WithReason(ReasonBodyTooLarge)
+	errPartTooLarge     = apierror.New(http.StatusRequestEntityTooLarge, apierror.CodeImageTooLarge, "Form part exceeds the maximum allowed size").WithReason(ReasonPartTooLarge)
+	errUnsupportedMedia = apierror.New(http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType, "Request body must be a jpeg, png, gif or bmp image, or a multipart/form-data form")
+	errImageNotFound    = apierror.New(http.StatusBadRequest, apierror.CodeImageMissing, "No image found in request body")
+	errMultipleImages   = apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, "Only one image may be uploaded per request")
+	errReadFailed       = apierror.New(http.StatusBadRequest, apierror.CodeInvalidRequest, "Error reading request body")
+	errAutoOrient       = apierror.New(http.StatusBadRequest, apierror.CodeInvalidParameter, "auto_orient must be true or false")
+	errDecodeFailed     = apierror.New(http.StatusUnprocessableEntity, apierror.CodeDecodeFailed, "Error decoding image")
+	errDeadlineExceeded = apierror.New(http.StatusGatewayTimeout, apierror.CodeDeadlineExceeded, "Processing deadline exceeded")
+	errCanceled         = apierror.New(http.StatusServiceUnavailable, apierror.CodeCanceled, "Request was canceled")
+)
+
+type config struct {
+	maxBodySize      int64
+	maxImagePartSize int64
+	maxFieldSize     int64
+	limits           imageService.Limits
+	budget           *admission.Budget
+	pool             *imageService.Pool
+}
+
+// Option customizes the behaviour of ParseImage.
+type Option func(*config)
 
+// WithMaxBodySize limits the size in bytes of the request body, whether it
+// is a raw image or a multipart form.
+func WithMaxBodySize(n int64) Option {
+	return func(cfg *config) {
+		cfg.maxBodySize = n
+	}
+}
+
+// WithMaxImagePartSize limits the size in bytes of the image part of a
+// multipart upload.
+func WithMaxImagePartSize(n int64) Option {
+	return func(cfg *config) {
+		cfg.maxImagePartSize = n
+	}
+}
+
+// WithMaxFieldSize limits the size in bytes of the auxiliary fields of a
+// multipart upload, such as kernel or quality.
+func WithMaxFieldSize(n int64) Option {
+	return func(cfg *config) {
+		cfg.maxFieldSize = n
+	}
+}
+
+// WithLimits bounds the dimensions of the uploaded images.
+func WithLimits(limits imageService.Limits) Option {
+	return func(cfg *config) {
+		cfg.limits = limits
+	}
+}
+
+// WithBudget makes every request reserve the pixels of its image from
+// budget before decoding it, until the handlers are done with it.
+func WithBudget(budget *admission.Budget) Option {
+	return func(cfg *config) {
+		cfg.budget = budget
+	}
+}
+
+// WithPool turns the images upright on pool instead of the goroutine of
+// the request.
+func WithPool(pool *imageService.Pool) Option {
+	return func(cfg *config) {
+		cfg.pool = pool
+	}
+}
+
+// ParseImage decodes the image of a request and stores it in the context
+// under "image", along with its format under "format". The image is taken
+// either from a raw body with an image Content-Type or from the "image"
+// part of a multipart form, in which case the remaining form fields are
+// stored under "fields". Other bodies get a 415 and images that cannot be
+// decoded a 422. Images are turned upright as their EXIF orientation
+// tells, unless auto_orient is false. Bodies, parts and images beyond the
+// configured limits are rejected with a 413 whose reason tells which limit
+// was hit, before the pixels of the image are decoded. Requests that
+// cannot reserve their pixels from the budget in time get a 503 with a
+// Retry-After.
+func ParseImage(opts ...Option) gin.HandlerFunc {
+	cfg := config{
+		maxBodySize:      DefaultMaxBodySize,
+		maxImagePartSize: DefaultMaxImagePartSize,
+		maxFieldSize:     DefaultMaxFieldSize,
+		limits:           imageService.DefaultLimits(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(c *gin.Context) {
 		contentType := c.Request.Header.Get("Content-Type")
-		if !strings.Contains(contentType, "image/jpeg") {
-			c.JSON(400, gin.H{"message": "No image found in request body"})
-			c.Abort()
+		mediaType, params, _ := mime.ParseMediaType(contentType)
+
+		var file []byte
+		var err error
+
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, cfg.maxBodySize)
+		switch _, ok := imageService.FormatFromContentType(contentType); {
+		case ok:
+			file, err = io.ReadAll(body)
+		case mediaType == "multipart/form-data":
+			var fields map[string]string
+			file, fields, err = readMultipart(body, params["boundary"], cfg)
+			c.Set("fields", fields)
+		default:
+			err = errUnsupportedMedia
+		}
+
+		var maxBytesErr *http.MaxBytesError
+		var apiErr *apierror.Error
+		switch {
+		case errors.As(err, &maxBytesErr):
+			apierror.Abort(c, errBodyTooLarge)
+			return
+		case errors.As(err, &apiErr):
+			apierror.Abort(c, apiErr)
+			return
+		case err != nil:
+			apierror.Abort(c, errReadFailed)
+			return
+		}
+
+		enabled, err := autoOrient(c)
+		if err != nil {
+			apierror.Abort(c, errAutoOrient)
 			return
 		}
+		orientation := 1
+		if enabled {
+			orientation = imageService.ExifOrientation(file)
+		}
 
-		file, err := io.ReadAll(c.Request.Body)
+		header, err := imageService.ReadHeader(bytes.NewReader(file), cfg.limits)
+		// Orientations from 5 on swap the sides of the image.
+		if err == nil && orientation >= 5 {
+			err = cfg.limits.Check(header.Height, header.Width)
+		}
 
+		var limitErr *imageService.LimitError
+		if errors.As(err, &limitErr) {
+			apierror.Abort(c, apierror.Wrap(http.StatusRequestEntityTooLarge, apierror.CodeImageTooLarge, err).WithReason(limitErr.Reason))
+			return
+		}
 		if err != nil {
-			c.JSON(400, gin.H{"message": "Error reading file"})
-			c.Abort()
+			apierror.Abort(c, errDecodeFailed)
 			return
 		}
 
-		img, _, err := image.Decode(bytes.NewReader(file))
+		// The pixels stay reserved until the response is encoded.
+		release, err := cfg.budget.Acquire(c.Request.Context(), header.Pixels())
+		switch {
+		case errors.Is(err, admission.ErrBudgetExhausted):
+			apierror.Abort(c, apierror.Wrap(http.StatusServiceUnavailable, apierror.CodeBusy, err).WithRetryAfter(admission.RetryAfter))
+			return
+		case errors.Is(err, context.DeadlineExceeded):
+			apierror.Abort(c, errDeadlineExceeded)
+			return
+		case err != nil:
+			apierror.Abort(c, errCanceled)
+			return
+		}
+		defer release()
 
+		img, err := header.Decode()
 		if err != nil {
-			c.JSON(400, gin.H{"message": "Error decoding image"})
-			c.Abort()
+			apierror.Abort(c, errDecodeFailed)
+			return
+		}
+
+		img, err = imageService.Orient(c.Request.Context(), cfg.pool, img, orientation)
+		switch {
+		case errors.Is(err, context.DeadlineExceeded):
+			apierror.Abort(c, errDeadlineExceeded)
+			return
+		case err != nil:
+			apierror.Abort(c, errCanceled)
 			return
 		}
 
 		c.Set("image", img)
+		c.Set("format", header.Format)
 
 		c.Next()
 	}
 }
+
+// autoOrient tells whether the image of the request is to be turned
+// upright, which is disabled by a false auto_orient in the query string or
+// the form.
+func autoOrient(c *gin.Context) (bool, error) {
+	value := c.Query(autoOrientParam)
+	if fields, ok := c.Get("fields"); ok && value == "" {
+		value = fields.(map[string]string)[autoOrientParam]
+	}
+	if value == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// readMultipart streams a multipart form, returning the contents of its
+// image part and the values of every other field.
+func readMultipart(body io.Reader, boundary string, cfg config) ([]byte, map[string]string, error) {
+	if boundary == "" {
+		return nil, nil, errImageNotFound
+	}
+
+	var file []byte
+	fields := map[string]string{}
+
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		name := part.FormName()
+		if name == imageField {
+			if file != nil {
+				return nil, nil, errMultipleImages
+			}
+			if file, err = readPart(part, cfg.maxImagePartSize); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		value, err := readPart(part, cfg.maxFieldSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, exists := fields[name]; !exists && name != "" {
+			fields[name] = string(value)
+		}
+	}
+
+	if file == nil {
+		return nil, nil, errImageNotFound
+	}
+
+	return file, fields, nil
+}
+
+func readPart(part *multipart.Part, limit int64) ([]byte, error) {
+	defer part.Close()
+
+	data, err := io.ReadAll(io.LimitReader(part, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errPartTooLarge
+	}
+	return data, nil
+}
@@ -1,113 +1,25 @@
 package middleware
 
-// import (
-// 	"bytes"
-// 	"fmt"
-// 	"io"
-// 	"net/http"
-// 	"net/http/httptest"
-// 	"testing"
-//
-// 	"github.com/gin-gonic/gin"
-// 	"github.com/stretchr/testify/assert"
-// )
-//
-// func TestParseImageMiddleware(t *testing.T) {
-// 	// Create a new Gin router and add the middleware to it
-// 	router := gin.New()
-// 	router.Use(ParseImage())
-//
-// 	t.Run("NoMultipartFormError", func(t *testing.T) {
-// 		req, err := http.NewRequest("POST", "/upload", nil)
-// 		assert.NoError(t, err)
-//
-// 		w := httptest.NewRecorder()
-// 		router.ServeHTTP(w, req)
-//
-// 		assert.Equal(t, http.StatusBadRequest, w.Code)
-// 		assert.Contains(t, w.Body.String(), "no multipart form")
-// 	})
-//
-// 	t.Run("ErrorParsingFormData", func(t *testing.T) {
-// 		body := new(bytes.Buffer)
-// 		body.WriteString("invalid")
-//
-// 		req, err := http.NewRequest("POST", "/upload", body)
-// 		assert.NoError(t, err)
-// 		req.Header.Set("Content-Type", "multipart/form-data")
-//
-// 		w := httptest.NewRecorder()
-// 		router.ServeHTTP(w, req)
-//
-// 		assert.Equal(t, http.StatusBadRequest, w.Code)
-// 		assert.Contains(t, w.Body.String(), "Error parsing form data")
-// 	})
-//
-// 	t.Run("FileNotFoundError", func(t *testing.T) {
-// 		body := new(bytes.Buffer)
-// 		req, err := http.NewRequest("POST", "/upload", body)
-// 		assert.NoError(t, err)
-//
-// 		form := new(bytes.Buffer)
-// 		form.WriteString(fmt.Sprintf("--%s\r\n", "boundary"))
-// 		form.WriteString(
-// 			fmt.Sprintf(
-// 				"Content-Disposition: form-data; name=\"%s\"; filename=\"%s\"\r\n",
-// 				"file",
-// 				"example.jpg",
-// 			),
-// 		)
-// 		form.WriteString("Content-Type: application/octet-stream\r\n\r\n")
-// 		form.WriteString("file content here\r\n")
-// 		form.WriteString(fmt.Sprintf("--%s--\r\n", "boundary"))
-//
-// 		req.Body = http.MaxBytesReader(nil, io.NopCloser(form), 32<<20)
-// 		req.Header.Set("Content-Type", "multipart/form-data; boundary=boundary")
-//
-// 		w := httptest.NewRecorder()
-// 		router.ServeHTTP(w, req)
-//
-// 		assert.Equal(t, http.StatusBadRequest, w.Code)
-// 		assert.Contains(t, w.Body.String(), "File not found in form data")
-// 	})
-//
-// 	t.Run("Success", func(t *testing.T) {
-// 		body := new(bytes.Buffer)
-// 		req, err := http.NewRequest("POST", "/upload", body)
-// 		assert.NoError(t, err)
-//
-// 		form := new(bytes.Buffer)
-// 		form.WriteString(fmt.Sprintf("--%s\r\n", "boundary"))
-// 		form.WriteString(
-// 			fmt.Sprintf(
-// 				"Content-Disposition: form-data; name=\"%s\"; filename=\"%s\"\r\n",
-// 				"file",
-// 				"example.jpg",
-// 			),
-// 		)
-// 		form.WriteString("Content-Type: application/octet-stream\r\n\r\n")
-// 		form.WriteString("file content here\r\n")
-// 		form.WriteString(fmt.Sprintf("--%s--\r\n", "boundary"))
-//
-// 		req.Body = http.MaxBytesReader(nil, io.NopCloser(form), 32<<20)
-// 		req.Header.Set("Content-Type", "multipart/form-data; boundary=boundary")
-//
-// 		w := httptest.NewRecorder()
-// 		router.ServeHTTP(w, req)
-//
-// 		assert.Equal(t, http.StatusOK, w.Code)
-// 	})
-// }
-
 import (
 	"bytes"
+	"context"
 	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/image/bmp"
+
+	"github.com/drew138/graphics-api/internal/admission"
+	imageService "github.com/drew138/graphics-api/internal/image"
 )
 
 // Mock error reader for testing error scenarios
@@ -128,12 +40,12 @@ func TestParseImage_InvalidContentType(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected status code %d, got %d", http.StatusUnsupportedMediaType, w.Code)
 	}
 
-	// Check error message in response body
-	expectedError := "No image found in request body"
+	// Check error code in response body
+	expectedError := `"code":"unsupported_media_type"`
 	if !strings.Contains(w.Body.String(), expectedError) {
 		t.Errorf("expected error message '%s', got '%s'", expectedError, w.Body.String())
 	}
@@ -155,7 +67,7 @@ func TestParseImage_ErrorReadingFile(t *testing.T) {
 	}
 
 	// Check error message in response body
-	expectedError := "Error reading file"
+	expectedError := "Error reading request body"
 	if !strings.Contains(w.Body.String(), expectedError) {
 		t.Errorf("expected error message '%s', got '%s'", expectedError, w.Body.String())
 	}
@@ -173,8 +85,8 @@ func TestParseImage_ErrorDecodingImage(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
-	if w.Code != http.StatusBadRequest {
-		t.Errorf("expected status code %d, got %d", http.StatusBadRequest, w.Code)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status code %d, got %d", http.StatusUnprocessableEntity, w.Code)
 	}
 
 	// Check error message in response body
@@ -183,3 +95,363 @@ func TestParseImage_ErrorDecodingImage(t *testing.T) {
 		t.Errorf("expected error message '%s', got '%s'", expectedError, w.Body.String())
 	}
 }
+
+func TestParseImage_SupportedFormats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	encoders := map[string]func(*bytes.Buffer) error{
+		"image/jpeg": func(b *bytes.Buffer) error { return jpeg.Encode(b, img, nil) },
+		"image/png":  func(b *bytes.Buffer) error { return png.Encode(b, img) },
+		"image/gif":  func(b *bytes.Buffer) error { return gif.Encode(b, img, nil) },
+		"image/bmp":  func(b *bytes.Buffer) error { return bmp.Encode(b, img) },
+	}
+
+	for contentType, encode := range encoders {
+		buf := new(bytes.Buffer)
+		if err := encode(buf); err != nil {
+			t.Fatalf("failed to encode %s: %v", contentType, err)
+		}
+
+		var format string
+		router := gin.New()
+		router.Use(ParseImage())
+		router.POST("/", func(c *gin.Context) {
+			format = c.GetString("format")
+			c.Status(http.StatusOK)
+		})
+
+		req, _ := http.NewRequest("POST", "/", buf)
+		req.Header.Set("Content-Type", contentType)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status code %d, got %d", contentType, http.StatusOK, w.Code)
+		}
+		if "image/"+format != contentType {
+			t.Errorf("%s: unexpected decoded format '%s'", contentType, format)
+		}
+	}
+}
+
+func multipartBody(t *testing.T, image []byte, fields map[string]string) (*bytes.Buffer, string) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	for name, value := range fields {
+		if err := writer.WriteField(name, value); err != nil {
+			t.Fatalf("failed to write field %s: %v", name, err)
+		}
+	}
+	if image != nil {
+		part, err := writer.CreateFormFile("image", "image.png")
+		if err != nil {
+			t.Fatalf("failed to create image part: %v", err)
+		}
+		_, _ = part.Write(image)
+	}
+	_ = writer.Close()
+
+	return body, writer.FormDataContentType()
+}
+
+func TestParseImage_Multipart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	body, contentType := multipartBody(t, buf.Bytes(), map[string]string{
+		"kernel":  "[[0,0,0],[0,1,0],[0,0,0]]",
+		"quality": "90",
+	})
+
+	var format string
+	var fields map[string]string
+	router := gin.New()
+	router.Use(ParseImage())
+	router.POST("/", func(c *gin.Context) {
+		format = c.GetString("format")
+		fields = c.MustGet("fields").(map[string]string)
+		c.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("POST", "/", body)
+	req.Header.Set("Content-Type", contentType)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if format != "png" {
+		t.Errorf("expected format 'png', got '%s'", format)
+	}
+	if fields["kernel"] != "[[0,0,0],[0,1,0],[0,0,0]]" || fields["quality"] != "90" {
+		t.Errorf("unexpected form fields %v", fields)
+	}
+}
+
+func TestParseImage_MultipartErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+
+	cases := []struct {
+		name     string
+		image    []byte
+		fields   map[string]string
+		opts     []Option
+		status   int
+		expected string
+	}{
+		{
+			name:     "ImageNotFound",
+			fields:   map[string]string{"kernel": "[[1]]"},
+			status:   http.StatusBadRequest,
+			expected: "No image found in request body",
+		},
+		{
+			name:     "ImagePartTooLarge",
+			image:    buf.Bytes(),
+			opts:     []Option{WithMaxImagePartSize(16)},
+			status:   http.StatusRequestEntityTooLarge,
+			expected: `"reason":"part_too_large"`,
+		},
+		{
+			name:     "FieldTooLarge",
+			image:    buf.Bytes(),
+			fields:   map[string]string{"kernel": strings.Repeat("1", 32)},
+			opts:     []Option{WithMaxFieldSize(16)},
+			status:   http.StatusRequestEntityTooLarge,
+			expected: "Form part exceeds the maximum allowed size",
+		},
+		{
+			name:     "BodyTooLarge",
+			image:    buf.Bytes(),
+			opts:     []Option{WithMaxBodySize(64)},
+			status:   http.StatusRequestEntityTooLarge,
+			expected: `"reason":"body_too_large"`,
+		},
+		{
+			name:     "ErrorDecodingImage",
+			image:    []byte("not an image"),
+			status:   http.StatusUnprocessableEntity,
+			expected: "Error decoding image",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(ParseImage(tc.opts...))
+
+			body, contentType := multipartBody(t, tc.image, tc.fields)
+			req, _ := http.NewRequest("POST", "/", body)
+			req.Header.Set("Content-Type", contentType)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Errorf("expected status code %d, got %d", tc.status, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tc.expected) {
+				t.Errorf("expected error message '%s', got '%s'", tc.expected, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestParseImage_Limits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 20, 10)))
+
+	cases := []struct {
+		name   string
+		opts   []Option
+		reason string
+	}{
+		{"BodyTooLarge", []Option{WithMaxBodySize(16)}, "body_too_large"},
+		{"WidthExceeded", []Option{WithLimits(imageService.Limits{MaxWidth: 16})}, "width_exceeded"},
+		{"HeightExceeded", []Option{WithLimits(imageService.Limits{MaxHeight: 8})}, "height_exceeded"},
+		{"PixelsExceeded", []Option{WithLimits(imageService.Limits{MaxPixels: 199})}, "pixels_exceeded"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(ParseImage(tc.opts...))
+			router.POST("/", func(c *gin.Context) {
+				t.Error("expected the request to be rejected")
+			})
+
+			req, _ := http.NewRequest("POST", "/", bytes.NewReader(buf.Bytes()))
+			req.Header.Set("Content-Type", "image/png")
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("expected status code %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+			}
+			if expected := `"reason":"` + tc.reason + `"`; !strings.Contains(w.Body.String(), expected) {
+				t.Errorf("expected '%s' in response, got '%s'", expected, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestParseImage_Budget(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+
+	budget := admission.NewBudget(150, 10*time.Millisecond)
+	router := gin.New()
+	router.Use(ParseImage(WithBudget(budget)))
+	router.POST("/", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	serve := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/", bytes.NewReader(buf.Bytes()))
+		req.Header.Set("Content-Type", "image/png")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The pixels of a request are released once it is done.
+	for i := 0; i < 3; i++ {
+		if w := serve(); w.Code != http.StatusOK {
+			t.Fatalf("expected status code %d, got %d", http.StatusOK, w.Code)
+		}
+	}
+
+	release, _ := budget.Acquire(context.Background(), 100)
+	defer release()
+
+	w := serve()
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status code %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After header, got '%s'", w.Header().Get("Retry-After"))
+	}
+	if !strings.Contains(w.Body.String(), `"code":"busy"`) {
+		t.Errorf("expected busy code, got '%s'", w.Body.String())
+	}
+}
+
+// orientedJPEG returns a jpeg of the given size whose EXIF metadata holds
+// orientation.
+func orientedJPEG(t *testing.T, width, height int, orientation byte) []byte {
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("failed to encode jpeg: %v", err)
+	}
+
+	// A big endian TIFF structure whose first IFD holds the orientation.
+	tiff := []byte{'M', 'M', 0, 42, 0, 0, 0, 8, 0, 1, 0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0, 0, 0, 0, 0}
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	data := []byte{0xff, 0xd8, 0xff, 0xe1, 0, byte(len(segment) + 2)}
+	data = append(data, segment...)
+	return append(data, buf.Bytes()[2:]...)
+}
+
+func TestParseImage_AutoOrient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	file := orientedJPEG(t, 20, 10, 6)
+	cases := []struct {
+		name   string
+		query  string
+		fields map[string]string
+		size   image.Point
+	}{
+		{"Default", "", nil, image.Pt(10, 20)},
+		{"Disabled", "auto_orient=false", nil, image.Pt(20, 10)},
+		{"DisabledInForm", "", map[string]string{"auto_orient": "0"}, image.Pt(20, 10)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var size image.Point
+			router := gin.New()
+			router.Use(ParseImage())
+			router.POST("/", func(c *gin.Context) {
+				size = c.MustGet("image").(image.Image).Bounds().Size()
+				c.Status(http.StatusOK)
+			})
+
+			var req *http.Request
+			if tc.fields != nil {
+				body, contentType := multipartBody(t, file, tc.fields)
+				req, _ = http.NewRequest("POST", "/?"+tc.query, body)
+				req.Header.Set("Content-Type", contentType)
+			} else {
+				req, _ = http.NewRequest("POST", "/?"+tc.query, bytes.NewReader(file))
+				req.Header.Set("Content-Type", "image/jpeg")
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+			if size != tc.size {
+				t.Errorf("expected an image of %v, got %v", tc.size, size)
+			}
+		})
+	}
+}
+
+func TestParseImage_AutoOrientErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		name     string
+		query    string
+		opts     []Option
+		canceled bool
+		status   int
+	}{
+		{"InvalidParameter", "auto_orient=maybe", nil, false, http.StatusBadRequest},
+		// The limits apply to the sides of the oriented image.
+		{"OrientedTooLarge", "", []Option{WithLimits(imageService.Limits{MaxHeight: 16})}, false, http.StatusRequestEntityTooLarge},
+		{"Canceled", "", []Option{WithPool(imageService.NewPool(2))}, true, http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(ParseImage(tc.opts...))
+			router.POST("/", func(c *gin.Context) {
+				t.Error("expected the request to be rejected")
+			})
+
+			req, _ := http.NewRequest("POST", "/?"+tc.query, bytes.NewReader(orientedJPEG(t, 20, 10, 6)))
+			req.Header.Set("Content-Type", "image/jpeg")
+			if tc.canceled {
+				ctx, cancel := context.WithCancel(req.Context())
+				cancel()
+				req = req.WithContext(ctx)
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tc.status {
+				t.Errorf("expected status code %d, got %d: %s", tc.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/drew138/graphics-api/api/apierror"
+)
+
+// RequestIDHeader carries the ID of a request, both ways.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID restricts the IDs accepted from clients to ones that are
+// safe to log and echo back.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
+
+// RequestID identifies every request by the ID given by the client, if
+// any, or by a random one. The ID is stored in the context under
+// apierror.RequestIDKey and echoed in the X-Request-ID header, so that
+// errors reported by clients can be traced.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		c.Set(apierror.RequestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/drew138/graphics-api/api/apierror"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := map[string]bool{
+		"":                  false,
+		"client-id.1":       true,
+		"spaces not valid":  false,
+		"\x00control-chars": false,
+	}
+	for header, kept := range cases {
+		router := gin.New()
+		router.Use(RequestID())
+
+		var stored string
+		router.GET("/", func(c *gin.Context) {
+			stored = c.GetString(apierror.RequestIDKey)
+		})
+
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set(RequestIDHeader, header)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		id := w.Header().Get(RequestIDHeader)
+		if id == "" || id != stored {
+			t.Errorf("%q: expected the stored ID '%s' to be echoed, got '%s'", header, stored, id)
+		}
+		if kept != (id == header) {
+			t.Errorf("%q: expected the ID to be kept: %v, got '%s'", header, kept, id)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+// Package openapi models the subset of OpenAPI 3 documents used to describe
+// the API, so that the document can be generated from the routes as they
+// are registered.
+package openapi
+
+import (
+	"strings"
+)
+
+// Version is the version of the OpenAPI specification documents follow.
+const Version = "3.0.3"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem maps the lower case methods of a path to their operation.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string              `json:"summary,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Deprecated  bool                `json:"deprecated,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+// Parameter locations.
+const (
+	InPath   = "path"
+	InQuery  = "query"
+	InHeader = "header"
+)
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Headers     map[string]Header    `json:"headers,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Ref returns a schema referring to the component schema called name.
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// Binary returns the schema of a file, such as an image.
+func Binary() *Schema {
+	return &Schema{Type: "string", Format: "binary"}
+}
+
+// New returns a document without paths.
+func New(info Info) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}
+}
+
+// Add documents the route registered for method at path, written in the
+// syntax of gin routes, e.g. /t/*path. Path parameters missing from op are
+// added to it, so that every route is a valid operation.
+func (d *Document) Add(method, path string, op *Operation) {
+	path, params := convertPath(path)
+
+	declared := map[string]bool{}
+	for _, p := range op.Parameters {
+		if p.In == InPath {
+			declared[p.Name] = true
+		}
+	}
+	for _, name := range params {
+		if !declared[name] {
+			op.Parameters = append(op.Parameters, Parameter{Name: name, In: InPath, Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+
+	item, ok := d.Paths[path]
+	if !ok {
+		item = PathItem{}
+		d.Paths[path] = item
+	}
+	item[strings.ToLower(method)] = op
+}
+
+// Has reports whether an operation is documented for method at path,
+// written in the syntax of gin routes.
+func (d *Document) Has(method, path string) bool {
+	path, _ = convertPath(path)
+	_, ok := d.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+// convertPath turns the :name and *name parameters of a gin path into
+// {name} templates, returning the names of the parameters.
+func convertPath(path string) (string, []string) {
+	var params []string
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			params = append(params, segment[1:])
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
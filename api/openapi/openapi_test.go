@@ -0,0 +1,27 @@
+package openapi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocument_Add(t *testing.T) {
+	doc := New(Info{Title: "test", Version: "1"})
+
+	doc.Add("GET", "/t/*path", &Operation{})
+	doc.Add("POST", "/filters/:name", &Operation{
+		Parameters: []Parameter{{Name: "name", In: InPath, Required: true, Description: "documented"}},
+	})
+
+	assert.True(t, doc.Has("GET", "/t/*path"))
+	assert.True(t, doc.Has("POST", "/filters/:name"))
+	assert.False(t, doc.Has("GET", "/filters/:name"))
+
+	op := doc.Paths["/t/{path}"]["get"]
+	assert.Equal(t, []Parameter{{Name: "path", In: InPath, Required: true, Schema: &Schema{Type: "string"}}}, op.Parameters)
+
+	op = doc.Paths["/filters/{name}"]["post"]
+	assert.Len(t, op.Parameters, 1)
+	assert.Equal(t, "documented", op.Parameters[0].Description)
+}
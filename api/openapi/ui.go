@@ -0,0 +1,22 @@
+package openapi
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:generate ./vendor-swagger-ui.sh 5.17.14
+
+// UI is a Swagger UI page rendering the document served at /openapi.json.
+// The page and the Swagger UI assets it loads from /docs/assets are both
+// embedded in the binary, so that browsing the documentation depends on
+// no third party.
+//
+//go:embed swagger-ui.html
+var UI []byte
+
+//go:embed all:swagger-ui
+var assets embed.FS
+
+// Assets holds the vendored Swagger UI assets, which go generate fetches.
+var Assets, _ = fs.Sub(assets, "swagger-ui")
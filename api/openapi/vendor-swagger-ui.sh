@@ -0,0 +1,15 @@
+#!/bin/sh
+# Vendors the Swagger UI assets embedded in the binary from the given
+# version of the swagger-ui-dist package. Run by go generate.
+set -eu
+
+version=$1
+dir=swagger-ui
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+curl -fsSL "https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-$version.tgz" | tar -xz -C "$tmp"
+mkdir -p "$dir"
+for file in swagger-ui.css swagger-ui-bundle.js LICENSE; do
+	cp "$tmp/package/$file" "$dir/$file"
+done
@@ -0,0 +1,217 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/drew138/graphics-api/api/apierror"
+	"github.com/drew138/graphics-api/api/middleware"
+	"github.com/drew138/graphics-api/api/openapi"
+	"github.com/drew138/graphics-api/internal/image"
+)
+
+// newSpec returns the OpenAPI document the routes are added to as they are
+// registered, with the schemas they share.
+func newSpec() *openapi.Document {
+	spec := openapi.New(openapi.Info{
+		Title:       "Graphics API",
+		Description: "Image processing on jpeg, png, gif and bmp images. Every response carries an X-Request-ID header.",
+		Version:     "1",
+	})
+
+	codes := []string{
+		apierror.CodeInvalidRequest, apierror.CodeImageMissing, apierror.CodeInvalidParameter,
+		apierror.CodeNotFound, apierror.CodeNotAcceptable, apierror.CodeImageTooLarge,
+		apierror.CodeUnsupportedMediaType, apierror.CodeDecodeFailed, apierror.CodeFilterFailed,
+		apierror.CodeInternal, apierror.CodeBusy, apierror.CodeCanceled, apierror.CodeDeadlineExceeded,
+	}
+	reasons := []string{
+		middleware.ReasonBodyTooLarge, middleware.ReasonPartTooLarge,
+		image.ReasonWidthExceeded, image.ReasonHeightExceeded, image.ReasonPixelsExceeded,
+	}
+	spec.Components.Schemas["Error"] = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"code":       {Type: "string", Enum: codes},
+			"message":    {Type: "string"},
+			"reason":     {Type: "string", Enum: reasons, Description: "Limit exceeded by image_too_large errors."},
+			"request_id": {Type: "string"},
+		},
+		Required: []string{"code", "message", "request_id"},
+	}
+	spec.Components.Schemas["Problem"] = &openapi.Schema{
+		Type:        "object",
+		Description: "RFC 7807 problem details, sent to clients accepting application/problem+json.",
+		Properties: map[string]*openapi.Schema{
+			"type":       {Type: "string"},
+			"title":      {Type: "string"},
+			"status":     {Type: "integer"},
+			"detail":     {Type: "string"},
+			"instance":   {Type: "string"},
+			"code":       {Type: "string", Enum: codes},
+			"reason":     {Type: "string", Enum: reasons},
+			"request_id": {Type: "string"},
+		},
+		Required: []string{"type", "title", "status", "code", "request_id"},
+	}
+	spec.Components.Schemas["Filter"] = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{
+			"name":        {Type: "string"},
+			"description": {Type: "string"},
+			"params": {Type: "array", Items: &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"name":        {Type: "string"},
+					"type":        {Type: "string", Enum: []string{image.ParamInteger, image.ParamNumber, image.ParamString, image.ParamBoolean, image.ParamKernel}},
+					"description": {Type: "string"},
+					"required":    {Type: "boolean"},
+					"minimum":     {Type: "number"},
+					"maximum":     {Type: "number"},
+					"enum":        {Type: "array", Items: &openapi.Schema{Type: "string"}},
+					"default":     {},
+					"header":      {Type: "string"},
+				},
+			}},
+			"kernel": {Type: "array", Items: &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "number"}}},
+			"formats": {Type: "object", Properties: map[string]*openapi.Schema{
+				"input":  {Type: "array", Items: &openapi.Schema{Type: "string"}},
+				"output": {Type: "array", Items: &openapi.Schema{Type: "string"}},
+			}},
+		},
+	}
+	return spec
+}
+
+// handle registers handlers for method at path on group, and adds doc to
+// the OpenAPI document.
+func (r *router) handle(group *gin.RouterGroup, method, path string, doc *openapi.Operation, handlers ...gin.HandlerFunc) {
+	group.Handle(method, path, handlers...)
+	r.spec.Add(method, strings.TrimSuffix(group.BasePath(), "/")+path, doc)
+}
+
+// errorResponses documents the errors a route may answer with.
+func errorResponses(responses map[string]openapi.Response, statuses ...int) map[string]openapi.Response {
+	for _, status := range statuses {
+		response := openapi.Response{
+			Description: http.StatusText(status),
+			Content: map[string]openapi.MediaType{
+				"application/json":        {Schema: openapi.Ref("Error")},
+				apierror.ProblemMediaType: {Schema: openapi.Ref("Problem")},
+			},
+		}
+		if status == http.StatusServiceUnavailable {
+			response.Headers = map[string]openapi.Header{
+				"Retry-After": {Description: "Seconds after which busy requests may be retried.", Schema: &openapi.Schema{Type: "integer"}},
+			}
+		}
+		responses[strconv.Itoa(status)] = response
+	}
+	return responses
+}
+
+// jsonOperation documents a route answering with a JSON document.
+func jsonOperation(tag, summary string, schema *openapi.Schema, errors ...int) *openapi.Operation {
+	return &openapi.Operation{
+		Summary: summary,
+		Tags:    []string{tag},
+		Responses: errorResponses(map[string]openapi.Response{
+			"200": {Description: "OK", Content: map[string]openapi.MediaType{"application/json": {Schema: schema}}},
+		}, errors...),
+	}
+}
+
+// imageResponse documents the image a route answers with, in any of the
+// output formats.
+func imageResponse() openapi.Response {
+	content := map[string]openapi.MediaType{}
+	for _, format := range image.Formats() {
+		content[image.MIMEType(format)] = openapi.MediaType{Schema: openapi.Binary()}
+	}
+	return openapi.Response{Description: "The processed image", Content: content}
+}
+
+// encoderParameters documents the options the processed images are
+// encoded with.
+func encoderParameters() []openapi.Parameter {
+	return []openapi.Parameter{
+		{Name: "format", In: openapi.InQuery, Description: "Output format. Defaults to the Accept header, then to the format of the input image.", Schema: &openapi.Schema{Type: "string", Enum: image.Formats()}},
+		{Name: "quality", In: openapi.InQuery, Description: "JPEG quality.", Schema: &openapi.Schema{Type: "integer", Minimum: bound(1), Maximum: bound(100)}},
+		{Name: "compression", In: openapi.InQuery, Description: "PNG compression level.", Schema: &openapi.Schema{Type: "string", Enum: []string{image.CompressionDefault, image.CompressionNone, image.CompressionFast, image.CompressionBest}}},
+		{Name: "colors", In: openapi.InQuery, Description: "GIF palette size.", Schema: &openapi.Schema{Type: "integer", Minimum: bound(2), Maximum: bound(256)}},
+		{Name: "dither", In: openapi.InQuery, Description: "GIF dithering mode.", Schema: &openapi.Schema{Type: "string", Enum: []string{image.DitherFloydSteinberg, image.DitherNone}}},
+	}
+}
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+// imageOperation documents a route receiving an image, either as the raw
+// body or as the image part of a form whose other parts may carry the
+// query parameters.
+func imageOperation(summary, description string, params ...openapi.Parameter) *openapi.Operation {
+	params = append(params, openapi.Parameter{Name: "auto_orient", In: openapi.InQuery, Description: "Whether the image is turned upright as its EXIF orientation tells before being processed.", Schema: &openapi.Schema{Type: "boolean", Default: true}})
+	params = append(params, encoderParameters()...)
+
+	content := map[string]openapi.MediaType{}
+	for _, format := range image.InputFormats() {
+		content[image.MIMEType(format)] = openapi.MediaType{Schema: openapi.Binary()}
+	}
+	form := &openapi.Schema{
+		Type:       "object",
+		Properties: map[string]*openapi.Schema{"image": openapi.Binary()},
+		Required:   []string{"image"},
+	}
+	for _, p := range params {
+		if p.In == openapi.InQuery {
+			form.Properties[p.Name] = p.Schema
+		}
+	}
+	content["multipart/form-data"] = openapi.MediaType{Schema: form}
+
+	return &openapi.Operation{
+		Summary:     summary,
+		Description: description,
+		Tags:        []string{"images"},
+		Parameters:  params,
+		RequestBody: &openapi.RequestBody{Required: true, Content: content},
+		Responses: errorResponses(map[string]openapi.Response{"200": imageResponse()},
+			http.StatusBadRequest, http.StatusNotAcceptable, http.StatusRequestEntityTooLarge,
+			http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity, http.StatusInternalServerError,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout),
+	}
+}
+
+// filterOperation documents the endpoint of filter, whose arguments are
+// query parameters or, when they have one, headers.
+func filterOperation(filter image.Filter) *openapi.Operation {
+	var params []openapi.Parameter
+	for _, p := range filter.Params {
+		schema := &openapi.Schema{Type: p.Type, Enum: p.Enum, Minimum: p.Minimum, Maximum: p.Maximum, Default: p.Default}
+		if p.Type == image.ParamKernel {
+			// Kernels are JSON matrices, whose bounds apply to every weight.
+			schema = &openapi.Schema{Type: "string", Description: "JSON matrix of numbers."}
+		}
+
+		params = append(params, openapi.Parameter{Name: p.Name, In: openapi.InQuery, Description: p.Description, Required: p.Required && p.Header == "", Schema: schema})
+		if p.Header != "" {
+			params = append(params, openapi.Parameter{Name: p.Header, In: openapi.InHeader, Description: fmt.Sprintf("Alternative to the %s parameter.", p.Name), Schema: schema})
+		}
+	}
+	return imageOperation("Apply "+filter.Name, filter.Description, params...)
+}
+
+// deprecate returns a copy of doc marking its route as deprecated in
+// favour of successor.
+func deprecate(doc *openapi.Operation, successor string) *openapi.Operation {
+	dep := *doc
+	dep.Deprecated = true
+	dep.Description = strings.TrimSpace(fmt.Sprintf("Deprecated alias of %s, removed after %s. %s", successor, legacySunset.Format("January 2, 2006"), doc.Description))
+	dep.Parameters = append([]openapi.Parameter{}, doc.Parameters...)
+	return &dep
+}
@@ -1,11 +1,19 @@
 package router
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 
+	"github.com/drew138/graphics-api/api/apierror"
 	"github.com/drew138/graphics-api/api/handler"
 	"github.com/drew138/graphics-api/api/middleware"
+	"github.com/drew138/graphics-api/api/openapi"
+	"github.com/drew138/graphics-api/internal/admission"
 	"github.com/drew138/graphics-api/internal/image"
+	"github.com/drew138/graphics-api/internal/server"
+	"github.com/drew138/graphics-api/internal/source"
 )
 
 type Router interface {
@@ -13,28 +21,232 @@ type Router interface {
 }
 
 type router struct {
-	eng *gin.Engine
+	eng       *gin.Engine
+	store     source.Store
+	deadline  time.Duration
+	limits    image.Limits
+	pixels    int64
+	wait      time.Duration
+	workers   int
+	filters   []string
+	maxAge    time.Duration
+	parseOpts []middleware.Option
+	readiness *server.Readiness
+	budget    *admission.Budget
+	pool      *image.Pool
+	spec      *openapi.Document
 }
 
-func NewRouter(eng *gin.Engine) Router {
-	return &router{eng: eng}
+// Option customizes the router built by NewRouter.
+type Option func(*router)
+
+// WithProcessingDeadline bounds the time spent processing an image. A
+// non-positive duration disables the deadline.
+func WithProcessingDeadline(d time.Duration) Option {
+	return func(r *router) {
+		r.deadline = d
+	}
 }
 
-func (r *router) setGlobalMiddleware() {
-	r.eng.Use(middleware.ParseImage())
+// WithImageLimits bounds the dimensions of the images accepted for
+// processing, whether uploaded or read from the store.
+func WithImageLimits(limits image.Limits) Option {
+	return func(r *router) {
+		r.limits = limits
+	}
+}
+
+// WithUploadLimits bounds the size in bytes of request bodies, and of the
+// image and other parts of multipart forms.
+func WithUploadLimits(body, imagePart, field int64) Option {
+	return func(r *router) {
+		r.parseOpts = append(r.parseOpts,
+			middleware.WithMaxBodySize(body),
+			middleware.WithMaxImagePartSize(imagePart),
+			middleware.WithMaxFieldSize(field),
+		)
+	}
+}
+
+// WithPixelBudget bounds the pixels of the images processed at once across
+// every request, which wait at most wait for their share. A non-positive
+// budget disables admission control.
+func WithPixelBudget(pixels int64, wait time.Duration) Option {
+	return func(r *router) {
+		r.pixels = pixels
+		r.wait = wait
+	}
+}
+
+// WithWorkers sets the number of goroutines filters run on.
+func WithWorkers(n int) Option {
+	return func(r *router) {
+		r.workers = n
+	}
+}
+
+// WithFilters restricts the filters served, and the operations pipelines
+// and transformations may use, to names. By default every filter is
+// served.
+func WithFilters(names ...string) Option {
+	return func(r *router) {
+		r.filters = names
+	}
+}
+
+// WithCacheMaxAge sets how long intermediaries may cache transformed
+// images.
+func WithCacheMaxAge(d time.Duration) Option {
+	return func(r *router) {
+		r.maxAge = d
+	}
+}
+
+// WithReadiness reports the readiness of the server, which defaults to
+// always being ready.
+func WithReadiness(readiness *server.Readiness) Option {
+	return func(r *router) {
+		r.readiness = readiness
+	}
+}
+
+func NewRouter(eng *gin.Engine, store source.Store, opts ...Option) Router {
+	r := &router{
+		eng:       eng,
+		store:     store,
+		deadline:  middleware.DefaultProcessingDeadline,
+		limits:    image.DefaultLimits(),
+		pixels:    admission.DefaultPixelBudget,
+		wait:      admission.DefaultWait,
+		maxAge:    handler.DefaultTransformMaxAge,
+		readiness: &server.Readiness{},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 func (r *router) MapRoutes() {
-	r.setGlobalMiddleware()
-	r.buildImageRoutes()
+	r.pool = image.NewPool(r.workers)
+	r.budget = admission.NewBudget(r.pixels, r.wait)
+	serviceOpts := []image.Option{image.WithPool(r.pool), image.WithBudget(r.budget)}
+	if len(r.filters) > 0 {
+		serviceOpts = append(serviceOpts, image.WithOperations(r.filters...))
+	}
+	service := image.NewService(serviceOpts...)
+	r.spec = newSpec()
+
+	r.eng.Use(middleware.RequestID())
+	r.eng.NoRoute(func(c *gin.Context) {
+		apierror.Abort(c, apierror.New(http.StatusNotFound, apierror.CodeNotFound, "Route not found"))
+	})
+
+	// Every route only carries the middleware it needs, so that routes
+	// taking no image coexist with those parsing one.
+	r.buildHealthRoutes(r.pool)
+	r.buildAPIRoutes(service)
+	r.buildTransformRoutes(service)
+	r.buildDocsRoutes()
+}
+
+// buildHealthRoutes registers the routes used by orchestrators, which take
+// no image.
+func (r *router) buildHealthRoutes(pool *image.Pool) {
+	handler := handler.NewHealth(r.readiness, pool, r.store)
+	root := &r.eng.RouterGroup
+	status := &openapi.Schema{Type: "object", AdditionalProperties: &openapi.Schema{}}
+
+	r.handle(root, http.MethodGet, "/healthz", jsonOperation("health", "Liveness", status), handler.GetLiveness())
+	r.handle(root, http.MethodGet, "/readyz", jsonOperation("health", "Readiness", status, http.StatusServiceUnavailable), handler.GetReadiness())
+	r.handle(root, http.MethodGet, "/version", jsonOperation("health", "Build version", status), handler.GetVersion())
+}
+
+// enabled reports whether the filter called name is served.
+func (r *router) enabled(name string) bool {
+	if len(r.filters) == 0 {
+		return true
+	}
+	for _, filter := range r.filters {
+		if filter == name {
+			return true
+		}
+	}
+	return false
+}
+
+// buildAPIRoutes mounts every version of the API, along with the legacy
+// aliases of the first one.
+func (r *router) buildAPIRoutes(service image.Service) {
+	versions := r.versions(service)
+	for _, v := range versions {
+		r.mount(r.eng.Group(v.prefix), v.routes)
+	}
+	r.mountLegacy(versions[0])
+}
+
+// imageMiddleware returns the middleware of the routes that receive an
+// image in their body.
+func (r *router) imageMiddleware() []gin.HandlerFunc {
+	parseOpts := append([]middleware.Option{middleware.WithLimits(r.limits), middleware.WithBudget(r.budget), middleware.WithPool(r.pool)}, r.parseOpts...)
+	return []gin.HandlerFunc{middleware.Deadline(r.deadline), middleware.ParseImage(parseOpts...)}
+}
+
+// mount registers routes on group, preceded by middleware.
+func (r *router) mount(group *gin.RouterGroup, routes []route, middleware ...gin.HandlerFunc) {
+	for _, rt := range routes {
+		handlers := append([]gin.HandlerFunc{}, middleware...)
+		if rt.image {
+			handlers = append(handlers, r.imageMiddleware()...)
+		}
+		r.handle(group, rt.method, rt.path, rt.doc, append(handlers, rt.handler)...)
+	}
+}
+
+func (r *router) buildTransformRoutes(service image.Service) {
+	handler := handler.NewTransform(service, r.store,
+		handler.WithSourceLimits(r.limits),
+		handler.WithBudget(r.budget),
+		handler.WithMaxAge(r.maxAge),
+	)
+
+	doc := &openapi.Operation{
+		Summary:     "Transform a source image",
+		Description: "Serves a source image transformed as described by the path, e.g. blur:2/sharpen/format:png/<source-id>. The path spans several segments. Requests for non canonical paths are redirected to the canonical one.",
+		Tags:        []string{"images"},
+		Parameters: []openapi.Parameter{
+			{Name: "path", In: openapi.InPath, Required: true, Description: "Operations and encoder options followed by the source image.", Schema: &openapi.Schema{Type: "string"}},
+			{Name: "If-None-Match", In: openapi.InHeader, Schema: &openapi.Schema{Type: "string"}},
+		},
+		Responses: errorResponses(map[string]openapi.Response{
+			"200": imageResponse(),
+			"301": {Description: "Redirect to the canonical path"},
+			"304": {Description: "Not modified"},
+		}, http.StatusBadRequest, http.StatusNotFound, http.StatusRequestEntityTooLarge,
+			http.StatusInternalServerError, http.StatusServiceUnavailable, http.StatusGatewayTimeout),
+	}
+
+	r.handle(&r.eng.RouterGroup, http.MethodGet, "/t/*path", doc, middleware.Deadline(r.deadline), handler.CreateTransformation())
 }
 
-func (r *router) buildImageRoutes() {
-	service := image.NewService()
-	handler := handler.NewImage(service)
+// buildDocsRoutes serves the OpenAPI document of every route registered
+// before, along with a page to browse it.
+func (r *router) buildDocsRoutes() {
+	handler := handler.NewDocs(r.spec)
+	root := &r.eng.RouterGroup
 
-	r.eng.POST("/sharpen", handler.CreateSharpen())
-	r.eng.POST("/edgedetection", handler.CreateEdgeDetection())
-	r.eng.POST("/gaussianblur", handler.CreateGaussianBlur())
-	r.eng.POST("/boxblur", handler.CreateBoxBlur())
+	spec := jsonOperation("docs", "OpenAPI document", &openapi.Schema{Type: "object"})
+	ui := &openapi.Operation{
+		Summary:   "Swagger UI",
+		Tags:      []string{"docs"},
+		Responses: map[string]openapi.Response{"200": {Description: "OK", Content: map[string]openapi.MediaType{"text/html": {}}}},
+	}
+	r.handle(root, http.MethodGet, "/openapi.json", spec, handler.GetSpec())
+	asset := &openapi.Operation{
+		Summary:   "Swagger UI assets",
+		Tags:      []string{"docs"},
+		Responses: map[string]openapi.Response{"200": {Description: "OK"}, "404": {Description: "Not Found"}},
+	}
+	r.handle(root, http.MethodGet, "/docs", ui, handler.GetUI())
+	r.handle(root, http.MethodGet, "/docs/assets/*file", asset, handler.GetAsset())
 }
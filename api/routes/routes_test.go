@@ -0,0 +1,216 @@
+package router
+
+import (
+	"bytes"
+	"encoding/json"
+	imagePkg "image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/drew138/graphics-api/internal/source"
+)
+
+func setupRouter(t *testing.T, opts ...Option) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	eng := gin.New()
+	NewRouter(eng, source.NewDir(t.TempDir()), opts...).MapRoutes()
+	return eng
+}
+
+func pngBody() *bytes.Reader {
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, imagePkg.NewRGBA(imagePkg.Rect(0, 0, 8, 8)))
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestRoutes_ImageRoutes(t *testing.T) {
+	eng := setupRouter(t)
+
+	for _, path := range []string{"/api/v1/filters/sharpen", "/api/v1/filters/custom?kernel=[[1]]", "/sharpen"} {
+		req, _ := http.NewRequest("POST", path, pngBody())
+		req.Header.Set("Content-Type", "image/png")
+		w := httptest.NewRecorder()
+		eng.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, "image/png", w.Header().Get("Content-Type"), path)
+	}
+
+	// Image routes still require an image.
+	req, _ := http.NewRequest("POST", "/api/v1/filters/sharpen", nil)
+	w := httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+// Routes that take no image must not go through ParseImage.
+func TestRoutes_NonImageRoutes(t *testing.T) {
+	eng := setupRouter(t)
+
+	for _, path := range []string{"/healthz", "/readyz", "/version"} {
+		req, _ := http.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		eng.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.NotContains(t, w.Body.String(), "unsupported_media_type", path)
+	}
+}
+
+func TestRoutes_DisabledFilters(t *testing.T) {
+	eng := setupRouter(t, WithFilters("sharpen"))
+
+	req, _ := http.NewRequest("POST", "/api/v1/filters/boxblur", pngBody())
+	req.Header.Set("Content-Type", "image/png")
+	w := httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRoutes_LegacyAliases(t *testing.T) {
+	eng := setupRouter(t)
+
+	for _, path := range []string{"/sharpen", "/api/sharpen", "/pipeline", "/api/pipeline"} {
+		req, _ := http.NewRequest("POST", path, nil)
+		w := httptest.NewRecorder()
+		eng.ServeHTTP(w, req)
+
+		// The headers are sent even when the request is rejected.
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, path)
+		assert.NotEmpty(t, w.Header().Get("Deprecation"), path)
+		assert.NotEmpty(t, w.Header().Get("Sunset"), path)
+	}
+
+	req, _ := http.NewRequest("POST", "/api/sharpen", nil)
+	w := httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+	assert.Equal(t, `</api/v1/filters/sharpen>; rel="successor-version"`, w.Header().Get("Link"))
+
+	req, _ = http.NewRequest("POST", "/api/v1/filters/sharpen", nil)
+	w = httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
+
+func TestOverride(t *testing.T) {
+	handler := func(*gin.Context) {}
+	base := []route{
+		{"POST", "/a", handler, true, nil},
+		{"POST", "/b", handler, true, nil},
+		{"GET", "/b", handler, false, nil},
+	}
+
+	routes := override(base,
+		route{"POST", "/a", handler, false, nil},
+		route{"POST", "/b", nil, false, nil},
+		route{"POST", "/c", handler, true, nil},
+		route{"POST", "/d", nil, false, nil},
+	)
+
+	var paths []string
+	for _, rt := range routes {
+		paths = append(paths, rt.method+" "+rt.path)
+	}
+	assert.Equal(t, []string{"POST /a", "GET /b", "POST /c"}, paths)
+	assert.False(t, routes[0].image)
+	assert.True(t, base[0].image, "base must not be modified")
+	assert.Len(t, base, 3)
+}
+
+func TestRoutes_Errors(t *testing.T) {
+	eng := setupRouter(t)
+
+	req, _ := http.NewRequest("GET", "/api/v1/unknown", nil)
+	req.Header.Set("X-Request-ID", "trace-1")
+	req.Header.Set("Accept", "application/problem+json")
+	w := httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "trace-1", w.Header().Get("X-Request-ID"))
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `"request_id":"trace-1"`)
+	assert.Contains(t, w.Body.String(), `"code":"not_found"`)
+}
+
+func TestRoutes_Filters(t *testing.T) {
+	eng := setupRouter(t, WithFilters("sharpen", "custom"))
+
+	req, _ := http.NewRequest("GET", "/api/v1/filters", nil)
+	w := httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"sharpen"`)
+	assert.Contains(t, w.Body.String(), `"name":"custom"`)
+	assert.NotContains(t, w.Body.String(), `"name":"boxblur"`)
+}
+
+// Every route must be documented, so that the OpenAPI document does not
+// drift from the routes actually served.
+func TestRoutes_OpenAPI(t *testing.T) {
+	eng := setupRouter(t)
+
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	w := httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+
+	params := regexp.MustCompile(`[:*]([^/]+)`)
+	for _, rt := range eng.Routes() {
+		path := params.ReplaceAllString(rt.Path, "{$1}")
+		assert.Contains(t, spec.Paths[path], strings.ToLower(rt.Method), "%s %s is not documented", rt.Method, rt.Path)
+	}
+
+	assert.Contains(t, string(spec.Paths["/sharpen"]["post"]), `"deprecated":true`)
+	assert.Contains(t, string(spec.Paths["/api/v1/filters/gaussianblur"]["post"]), `"name":"sigma"`)
+
+	req, _ = http.NewRequest("GET", "/docs", nil)
+	w = httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "/openapi.json")
+	// The page loads nothing from third parties.
+	assert.NotContains(t, w.Body.String(), "https://")
+
+	req, _ = http.NewRequest("GET", "/docs/assets/missing.js", nil)
+	w = httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRoutes_Resize(t *testing.T) {
+	eng := setupRouter(t)
+
+	req, _ := http.NewRequest("POST", "/api/v1/filters/resize?width=4&height=2&fit=fill", pngBody())
+	req.Header.Set("Content-Type", "image/png")
+	w := httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	img, err := png.Decode(w.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, imagePkg.Pt(4, 2), img.Bounds().Size())
+
+	// Filters added after versioning have no legacy alias.
+	req, _ = http.NewRequest("POST", "/resize?width=4", pngBody())
+	req.Header.Set("Content-Type", "image/png")
+	w = httptest.NewRecorder()
+	eng.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
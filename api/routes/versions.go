@@ -0,0 +1,139 @@
+package router
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/drew138/graphics-api/api/handler"
+	"github.com/drew138/graphics-api/api/middleware"
+	"github.com/drew138/graphics-api/api/openapi"
+	"github.com/drew138/graphics-api/internal/image"
+)
+
+// The unversioned routes are deprecated in favour of /api/v1 and will be
+// removed after legacySunset.
+var (
+	legacyDeprecation = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	legacySunset      = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// legacyRoutes are the paths of the routes of the first version that were
+// served before it was versioned. Routes added since have no alias.
+var legacyRoutes = []string{
+	"/filters/sharpen",
+	"/filters/edgedetection",
+	"/filters/gaussianblur",
+	"/filters/boxblur",
+	"/filters/custom",
+	"/pipeline",
+}
+
+// legacyPrefixes are the prefixes the routes of the first version used to
+// be served under: bare, as they were registered, and /api, as they were
+// documented.
+var legacyPrefixes = []string{"", "/api"}
+
+// route is an endpoint of a version of the API, whose path is relative to
+// the prefix of the version.
+type route struct {
+	method  string
+	path    string
+	handler gin.HandlerFunc
+	// image tells whether the route receives an image in its body.
+	image bool
+	doc   *openapi.Operation
+}
+
+// apiVersion is a version of the API mounted under prefix.
+type apiVersion struct {
+	prefix string
+	routes []route
+}
+
+// versions returns the versions of the API, oldest first. A new version
+// is introduced by overriding the routes of the previous one that change,
+// e.g. {prefix: "/api/v2", routes: override(v1, route{...})}, so that the
+// others are shared rather than duplicated.
+func (r *router) versions(service image.Service) []apiVersion {
+	v1 := r.v1Routes(service)
+
+	return []apiVersion{
+		{prefix: "/api/v1", routes: v1},
+	}
+}
+
+func (r *router) v1Routes(service image.Service) []route {
+	images := handler.NewImage(service)
+
+	var filters []image.Filter
+	for _, filter := range image.Filters() {
+		if r.enabled(filter.Name) {
+			filters = append(filters, filter)
+		}
+	}
+
+	list := jsonOperation("filters", "List the filters", &openapi.Schema{
+		Type:       "object",
+		Properties: map[string]*openapi.Schema{"filters": {Type: "array", Items: openapi.Ref("Filter")}},
+	})
+	routes := []route{{http.MethodGet, "/filters", handler.NewFilters(filters).GetFilters(), false, list}}
+
+	// Every registered filter gets an endpoint, served by the same handler.
+	for _, filter := range filters {
+		routes = append(routes, route{http.MethodPost, "/filters/" + filter.Name, images.ApplyFilter(filter), true, filterOperation(filter)})
+	}
+
+	pipeline := imageOperation("Apply a pipeline", "Applies a sequence of operations, given as a JSON list such as [{\"op\": \"gaussianblur\", \"sigma\": 2}, {\"op\": \"sharpen\"}].",
+		openapi.Parameter{Name: "operations", In: openapi.InQuery, Description: "Operations to apply, in order.", Schema: &openapi.Schema{Type: "string"}},
+		openapi.Parameter{Name: "X-Operations", In: openapi.InHeader, Description: "Alternative to the operations parameter.", Schema: &openapi.Schema{Type: "string"}},
+	)
+	routes = append(routes, route{http.MethodPost, "/pipeline", images.CreatePipeline(), true, pipeline})
+
+	return routes
+}
+
+// override returns the routes of base, with those sharing the method and
+// path of a change replaced by it and the other changes added. Changes
+// with a nil handler remove the route from the version.
+func override(base []route, changes ...route) []route {
+	routes := append([]route{}, base...)
+	for _, change := range changes {
+		i := 0
+		for i < len(routes) && (routes[i].method != change.method || routes[i].path != change.path) {
+			i++
+		}
+		switch {
+		case change.handler == nil && i < len(routes):
+			routes = append(routes[:i], routes[i+1:]...)
+		case change.handler == nil:
+		case i < len(routes):
+			routes[i] = change
+		default:
+			routes = append(routes, change)
+		}
+	}
+	return routes
+}
+
+// mountLegacy serves the legacy routes of v under their unversioned paths,
+// with headers announcing their deprecation and pointing to v.
+func (r *router) mountLegacy(v apiVersion) {
+	for _, rt := range v.routes {
+		if !slices.Contains(legacyRoutes, rt.path) {
+			continue
+		}
+
+		successor := v.prefix + rt.path
+		deprecated := middleware.Deprecated(legacyDeprecation, legacySunset, successor)
+		legacy := rt
+		legacy.path = strings.TrimPrefix(rt.path, "/filters")
+		legacy.doc = deprecate(rt.doc, successor)
+		for _, prefix := range legacyPrefixes {
+			r.mount(r.eng.Group(prefix), []route{legacy}, deprecated)
+		}
+	}
+}
@@ -1,19 +1,83 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/gin-gonic/gin"
 
 	router "github.com/drew138/graphics-api/api/routes"
+	"github.com/drew138/graphics-api/internal/config"
+	"github.com/drew138/graphics-api/internal/image"
+	"github.com/drew138/graphics-api/internal/server"
+	"github.com/drew138/graphics-api/internal/source"
 )
 
 func main() {
+	cfg, printConfig, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if printConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	gin.SetMode(cfg.Mode)
 	eng := gin.Default()
 
-	router := router.NewRouter(eng)
+	readiness := &server.Readiness{}
+	router := router.NewRouter(eng, source.NewDir(cfg.SourceDir),
+		router.WithReadiness(readiness),
+		router.WithProcessingDeadline(cfg.Timeouts.Processing),
+		router.WithImageLimits(image.Limits{
+			MaxWidth:  cfg.Limits.MaxWidth,
+			MaxHeight: cfg.Limits.MaxHeight,
+			MaxPixels: cfg.Limits.MaxPixels,
+		}),
+		router.WithUploadLimits(cfg.Limits.MaxBodySize, cfg.Limits.MaxImagePartSize, cfg.Limits.MaxFieldSize),
+		router.WithPixelBudget(cfg.Limits.PixelBudget, cfg.Timeouts.Admission),
+		router.WithWorkers(cfg.Workers),
+		router.WithFilters(cfg.Filters...),
+		router.WithCacheMaxAge(cfg.Cache.MaxAge),
+	)
 	router.MapRoutes()
 
-	if err := eng.Run("0.0.0.0:8080"); err != nil {
-		panic(err)
+	l, err := net.Listen("tcp", cfg.Addr)
+	if err != nil {
+		log.Fatal(err)
 	}
+	log.Printf("Listening on %s", l.Addr())
+
+	// Cloud Run sends SIGTERM before stopping an instance, which gives load
+	// balancers the shutdown delay to stop sending traffic, and then the
+	// in-flight requests the drain timeout to complete.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
+	srv := &http.Server{
+		Handler:           eng,
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader,
+		ReadTimeout:       cfg.Timeouts.Read,
+		IdleTimeout:       cfg.Timeouts.Idle,
+	}
+	if err := server.Serve(ctx, srv, l, readiness, cfg.Timeouts.ShutdownDelay, cfg.Timeouts.Drain); err != nil {
+		log.Fatal(err)
+	}
+	log.Print("Shut down")
 }
@@ -6,6 +6,9 @@ require (
 	github.com/drew138/go-graphics v0.0.0-20211231181100-ab2ebb1a0e19
 	github.com/gin-gonic/gin v1.9.1
 	github.com/stretchr/testify v1.8.3
+	golang.org/x/image v0.15.0
+	golang.org/x/sync v0.6.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -33,7 +36,6 @@ require (
 	golang.org/x/crypto v0.9.0 // indirect
 	golang.org/x/net v0.10.0 // indirect
 	golang.org/x/sys v0.8.0 // indirect
-	golang.org/x/text v0.9.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/protobuf v1.30.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
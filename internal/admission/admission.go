@@ -0,0 +1,75 @@
+package admission
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	// DefaultPixelBudget bounds the pixels of the images processed at once
+	// across every request unless configured otherwise, which keeps the
+	// decoded images and their intermediate copies within a few GB.
+	DefaultPixelBudget = 100_000_000
+	// DefaultWait is how long a request waits for its share of the budget
+	// before being turned away.
+	DefaultWait = 2 * time.Second
+	// RetryAfter is the delay, in seconds, suggested to the clients of
+	// requests turned away.
+	RetryAfter = 1
+)
+
+var ErrBudgetExhausted = errors.New("server is busy processing other images")
+
+// Budget is a server-wide semaphore measured in pixels, which bounds the
+// memory used by concurrent requests regardless of their number. A nil
+// *Budget admits every request.
+type Budget struct {
+	sem      *semaphore.Weighted
+	capacity int64
+	wait     time.Duration
+}
+
+// NewBudget returns a budget of capacity pixels, whose requests wait at
+// most wait for their share. A non-positive capacity disables admission
+// control.
+func NewBudget(capacity int64, wait time.Duration) *Budget {
+	if capacity <= 0 {
+		return nil
+	}
+	return &Budget{sem: semaphore.NewWeighted(capacity), capacity: capacity, wait: wait}
+}
+
+// Capacity returns the number of pixels of the budget, or 0 if it is
+// disabled.
+func (b *Budget) Capacity() int64 {
+	if b == nil {
+		return 0
+	}
+	return b.capacity
+}
+
+// Acquire reserves n pixels of the budget, queueing behind earlier
+// requests for at most the configured wait. It returns ErrBudgetExhausted
+// if the pixels could not be reserved in time, and otherwise a function
+// that gives them back. Requests larger than the whole budget are admitted
+// once it is entirely free.
+func (b *Budget) Acquire(ctx context.Context, n int64) (release func(), err error) {
+	if b == nil {
+		return func() {}, nil
+	}
+	n = min(max(n, 1), b.capacity)
+
+	waitCtx, cancel := context.WithTimeout(ctx, b.wait)
+	defer cancel()
+
+	if err := b.sem.Acquire(waitCtx, n); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, ErrBudgetExhausted
+	}
+	return func() { b.sem.Release(n) }, nil
+}
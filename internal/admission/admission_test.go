@@ -0,0 +1,64 @@
+package admission
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget_Acquire(t *testing.T) {
+	budget := NewBudget(100, 10*time.Millisecond)
+
+	release, err := budget.Acquire(context.Background(), 60)
+	assert.NoError(t, err)
+
+	_, err = budget.Acquire(context.Background(), 60)
+	assert.ErrorIs(t, err, ErrBudgetExhausted)
+
+	other, err := budget.Acquire(context.Background(), 40)
+	assert.NoError(t, err)
+
+	release()
+	other()
+
+	// Requests larger than the budget are admitted alone.
+	release, err = budget.Acquire(context.Background(), 1000)
+	assert.NoError(t, err)
+	release()
+}
+
+func TestBudget_Queueing(t *testing.T) {
+	budget := NewBudget(100, time.Second)
+
+	release, err := budget.Acquire(context.Background(), 100)
+	assert.NoError(t, err)
+	time.AfterFunc(10*time.Millisecond, release)
+
+	release, err = budget.Acquire(context.Background(), 100)
+	assert.NoError(t, err)
+	release()
+}
+
+func TestBudget_Canceled(t *testing.T) {
+	budget := NewBudget(100, time.Second)
+	release, _ := budget.Acquire(context.Background(), 100)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := budget.Acquire(ctx, 1)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestBudget_Disabled(t *testing.T) {
+	budget := NewBudget(0, time.Second)
+	assert.Nil(t, budget)
+	assert.Equal(t, int64(0), budget.Capacity())
+
+	release, err := budget.Acquire(context.Background(), 1<<40)
+	assert.NoError(t, err)
+	release()
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/drew138/graphics-api/api/middleware"
+	"github.com/drew138/graphics-api/internal/admission"
+	"github.com/drew138/graphics-api/internal/image"
+	"github.com/drew138/graphics-api/internal/server"
+)
+
+// Config is the configuration of the server.
+type Config struct {
+	// Addr is the address the server listens on.
+	Addr string `yaml:"addr"`
+	// Mode is the gin mode: debug, release or test.
+	Mode string `yaml:"mode"`
+	// SourceDir is the directory of the source images of transformations.
+	SourceDir string `yaml:"source_dir"`
+	// Workers is the number of goroutines filters run on, or 0 for as many
+	// as GOMAXPROCS.
+	Workers int `yaml:"workers"`
+	// Filters lists the enabled filters, or every filter if empty.
+	Filters []string `yaml:"filters"`
+
+	Limits   Limits   `yaml:"limits"`
+	Timeouts Timeouts `yaml:"timeouts"`
+	Cache    Cache    `yaml:"cache"`
+}
+
+// Limits bounds the resources used by requests. Image dimensions and the
+// pixel budget are not enforced when 0.
+type Limits struct {
+	MaxBodySize      int64 `yaml:"max_body_size"`
+	MaxImagePartSize int64 `yaml:"max_image_part_size"`
+	MaxFieldSize     int64 `yaml:"max_field_size"`
+	MaxWidth         int   `yaml:"max_width"`
+	MaxHeight        int   `yaml:"max_height"`
+	MaxPixels        int   `yaml:"max_pixels"`
+	PixelBudget      int64 `yaml:"pixel_budget"`
+}
+
+// Timeouts bounds the time spent on requests. The processing deadline and
+// the connection timeouts are not enforced when 0.
+type Timeouts struct {
+	Processing    time.Duration `yaml:"processing"`
+	Admission     time.Duration `yaml:"admission"`
+	Drain         time.Duration `yaml:"drain"`
+	ShutdownDelay time.Duration `yaml:"shutdown_delay"`
+	ReadHeader    time.Duration `yaml:"read_header"`
+	Read          time.Duration `yaml:"read"`
+	Idle          time.Duration `yaml:"idle"`
+}
+
+// Cache controls the caching of transformed images by intermediaries.
+type Cache struct {
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// Default returns the configuration used for settings that are not given.
+func Default() Config {
+	return Config{
+		Addr:      "0.0.0.0:8080",
+		Mode:      "debug",
+		SourceDir: "sources",
+		Limits: Limits{
+			MaxBodySize:      middleware.DefaultMaxBodySize,
+			MaxImagePartSize: middleware.DefaultMaxImagePartSize,
+			MaxFieldSize:     middleware.DefaultMaxFieldSize,
+			MaxWidth:         image.DefaultMaxWidth,
+			MaxHeight:        image.DefaultMaxHeight,
+			MaxPixels:        image.DefaultMaxPixels,
+			PixelBudget:      admission.DefaultPixelBudget,
+		},
+		Timeouts: Timeouts{
+			Processing:    middleware.DefaultProcessingDeadline,
+			Admission:     admission.DefaultWait,
+			Drain:         server.DefaultDrainTimeout,
+			ShutdownDelay: server.DefaultShutdownDelay,
+			ReadHeader:    server.DefaultReadHeaderTimeout,
+			Read:          server.DefaultReadTimeout,
+			Idle:          server.DefaultIdleTimeout,
+		},
+		Cache: Cache{
+			MaxAge: 24 * time.Hour,
+		},
+	}
+}
+
+// Load builds the configuration from, in increasing order of precedence,
+// the defaults, the YAML file named by the -config flag or the CONFIG_FILE
+// environment variable, environment variables and command line flags. It
+// also reports whether -print-config was given.
+func Load(args []string, getenv func(string) string) (Config, bool, error) {
+	// The file and the print mode are found first, since the file is
+	// applied before the environment and the flags.
+	var file string
+	var printConfig bool
+	discovery := newFlagSet(&Config{}, &file, &printConfig)
+	discovery.SetOutput(io.Discard)
+	if err := discovery.Parse(args); err != nil {
+		// Parsing again reports the error, or the usage, on stderr.
+		return Config{}, false, newFlagSet(&Config{}, &file, &printConfig).Parse(args)
+	}
+	if file == "" {
+		file = getenv("CONFIG_FILE")
+	}
+
+	cfg := Default()
+	if file != "" {
+		if err := readFile(file, &cfg); err != nil {
+			return Config{}, false, err
+		}
+	}
+
+	fs := newFlagSet(&cfg, &file, &printConfig)
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := fs.Set(s.flag, value); err != nil {
+				return Config{}, false, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, false, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, false, err
+	}
+	return cfg, printConfig, nil
+}
+
+func readFile(name string, cfg *Config) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("invalid config file %s: %w", name, err)
+	}
+	return nil
+}
+
+// setting binds a field of Config to a flag and an environment variable.
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	value func(cfg *Config) flag.Value
+}
+
+var settings = []setting{
+	{"addr", "ADDR", "address to listen on", func(cfg *Config) flag.Value { return (*stringValue)(&cfg.Addr) }},
+	{"mode", "GIN_MODE", "gin mode: debug, release or test", func(cfg *Config) flag.Value { return (*stringValue)(&cfg.Mode) }},
+	{"source-dir", "SOURCE_DIR", "directory of the source images", func(cfg *Config) flag.Value { return (*stringValue)(&cfg.SourceDir) }},
+	{"workers", "WORKERS", "goroutines filters run on, 0 for GOMAXPROCS", func(cfg *Config) flag.Value { return (*intValue)(&cfg.Workers) }},
+	{"filters", "FILTERS", "comma separated list of enabled filters, all if empty", func(cfg *Config) flag.Value { return (*listValue)(&cfg.Filters) }},
+	{"max-body-size", "MAX_BODY_SIZE", "maximum request body size in bytes", func(cfg *Config) flag.Value { return (*int64Value)(&cfg.Limits.MaxBodySize) }},
+	{"max-image-part-size", "MAX_IMAGE_PART_SIZE", "maximum size in bytes of the image part of a form", func(cfg *Config) flag.Value { return (*int64Value)(&cfg.Limits.MaxImagePartSize) }},
+	{"max-field-size", "MAX_FIELD_SIZE", "maximum size in bytes of the other parts of a form", func(cfg *Config) flag.Value { return (*int64Value)(&cfg.Limits.MaxFieldSize) }},
+	{"max-width", "MAX_WIDTH", "maximum image width in pixels", func(cfg *Config) flag.Value { return (*intValue)(&cfg.Limits.MaxWidth) }},
+	{"max-height", "MAX_HEIGHT", "maximum image height in pixels", func(cfg *Config) flag.Value { return (*intValue)(&cfg.Limits.MaxHeight) }},
+	{"max-pixels", "MAX_PIXELS", "maximum number of pixels of an image", func(cfg *Config) flag.Value { return (*intValue)(&cfg.Limits.MaxPixels) }},
+	{"pixel-budget", "PIXEL_BUDGET", "maximum number of pixels processed at once", func(cfg *Config) flag.Value { return (*int64Value)(&cfg.Limits.PixelBudget) }},
+	{"processing-deadline", "PROCESSING_DEADLINE", "maximum time spent processing a request", func(cfg *Config) flag.Value { return (*durationValue)(&cfg.Timeouts.Processing) }},
+	{"admission-wait", "ADMISSION_WAIT", "maximum time a request waits for the pixel budget", func(cfg *Config) flag.Value { return (*durationValue)(&cfg.Timeouts.Admission) }},
+	{"drain-timeout", "DRAIN_TIMEOUT", "maximum time in-flight requests are given to complete on shutdown", func(cfg *Config) flag.Value { return (*durationValue)(&cfg.Timeouts.Drain) }},
+	{"shutdown-delay", "SHUTDOWN_DELAY", "time the server keeps serving while not ready before draining on shutdown", func(cfg *Config) flag.Value { return (*durationValue)(&cfg.Timeouts.ShutdownDelay) }},
+	{"read-header-timeout", "READ_HEADER_TIMEOUT", "maximum time spent reading the headers of a request", func(cfg *Config) flag.Value { return (*durationValue)(&cfg.Timeouts.ReadHeader) }},
+	{"read-timeout", "READ_TIMEOUT", "maximum time spent reading a request, including its body", func(cfg *Config) flag.Value { return (*durationValue)(&cfg.Timeouts.Read) }},
+	{"idle-timeout", "IDLE_TIMEOUT", "maximum time a keep-alive connection waits for the next request", func(cfg *Config) flag.Value { return (*durationValue)(&cfg.Timeouts.Idle) }},
+	{"cache-max-age", "CACHE_MAX_AGE", "time transformed images may be cached for", func(cfg *Config) flag.Value { return (*durationValue)(&cfg.Cache.MaxAge) }},
+}
+
+func newFlagSet(cfg *Config, file *string, printConfig *bool) *flag.FlagSet {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(file, "config", "", "YAML configuration file (env CONFIG_FILE)")
+	fs.BoolVar(printConfig, "print-config", false, "print the configuration and exit")
+	for _, s := range settings {
+		fs.Var(s.value(cfg), s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	return fs
+}
+
+// Validate checks that every setting is within its accepted range.
+func (cfg Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(cfg.Addr != "", "addr must not be empty")
+	check(cfg.Mode == "debug" || cfg.Mode == "release" || cfg.Mode == "test", "mode must be debug, release or test")
+	check(cfg.SourceDir != "", "source_dir must not be empty")
+	check(cfg.Workers >= 0, "workers must not be negative")
+	for _, name := range cfg.Filters {
+		check(contains(image.Operations(), name), "unknown filter %q, must be one of %s", name, strings.Join(image.Operations(), ", "))
+	}
+
+	check(cfg.Limits.MaxBodySize > 0, "limits.max_body_size must be positive")
+	check(cfg.Limits.MaxImagePartSize > 0, "limits.max_image_part_size must be positive")
+	check(cfg.Limits.MaxFieldSize > 0, "limits.max_field_size must be positive")
+	check(cfg.Limits.MaxWidth >= 0, "limits.max_width must not be negative")
+	check(cfg.Limits.MaxHeight >= 0, "limits.max_height must not be negative")
+	check(cfg.Limits.MaxPixels >= 0, "limits.max_pixels must not be negative")
+	check(cfg.Limits.PixelBudget >= 0, "limits.pixel_budget must not be negative")
+
+	check(cfg.Timeouts.Processing >= 0, "timeouts.processing must not be negative")
+	check(cfg.Timeouts.Admission >= 0, "timeouts.admission must not be negative")
+	check(cfg.Timeouts.Drain >= 0, "timeouts.drain must not be negative")
+	check(cfg.Timeouts.ShutdownDelay >= 0, "timeouts.shutdown_delay must not be negative")
+	check(cfg.Timeouts.ReadHeader >= 0, "timeouts.read_header must not be negative")
+	check(cfg.Timeouts.Read >= 0, "timeouts.read must not be negative")
+	check(cfg.Timeouts.Read == 0 || cfg.Timeouts.ReadHeader <= cfg.Timeouts.Read, "timeouts.read_header must not exceed timeouts.read")
+	check(cfg.Timeouts.Idle >= 0, "timeouts.idle must not be negative")
+	check(cfg.Cache.MaxAge >= 0, "cache.max_age must not be negative")
+
+	return errors.Join(errs...)
+}
+
+// Print writes the configuration as YAML, in the format of the config file.
+func (cfg Config) Print(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(cfg); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func env(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func writeFile(t *testing.T, content string) string {
+	name := filepath.Join(t.TempDir(), "config.yaml")
+	_ = os.WriteFile(name, []byte(content), 0o644)
+	return name
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, printConfig, err := Load(nil, env(nil))
+	assert.NoError(t, err)
+	assert.False(t, printConfig)
+	assert.Equal(t, Default(), cfg)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	file := writeFile(t, `
+addr: file:1
+workers: 2
+filters: [sharpen]
+limits:
+  max_width: 100
+timeouts:
+  processing: 5s
+`)
+
+	cfg, _, err := Load([]string{"-workers", "4"}, env(map[string]string{
+		"CONFIG_FILE":  file,
+		"WORKERS":      "3",
+		"MAX_HEIGHT":   "200",
+		"IDLE_TIMEOUT": "30s",
+		"FILTERS":      "boxblur, custom",
+	}))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "file:1", cfg.Addr)
+	assert.Equal(t, 4, cfg.Workers)
+	assert.Equal(t, []string{"boxblur", "custom"}, cfg.Filters)
+	assert.Equal(t, 100, cfg.Limits.MaxWidth)
+	assert.Equal(t, 200, cfg.Limits.MaxHeight)
+	assert.Equal(t, 5*time.Second, cfg.Timeouts.Processing)
+	assert.Equal(t, 30*time.Second, cfg.Timeouts.Idle)
+	assert.Equal(t, Default().Timeouts.ReadHeader, cfg.Timeouts.ReadHeader)
+	assert.Equal(t, Default().Cache, cfg.Cache)
+}
+
+func TestLoad_ConfigFlag(t *testing.T) {
+	file := writeFile(t, "addr: flag:1\n")
+	other := writeFile(t, "addr: env:1\n")
+
+	cfg, printConfig, err := Load([]string{"--config", file, "--print-config"}, env(map[string]string{"CONFIG_FILE": other}))
+	assert.NoError(t, err)
+	assert.True(t, printConfig)
+	assert.Equal(t, "flag:1", cfg.Addr)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	cases := map[string]struct {
+		args []string
+		env  map[string]string
+		file string
+	}{
+		"UnknownFlag":   {args: []string{"-bogus"}},
+		"InvalidEnv":    {env: map[string]string{"WORKERS": "many"}},
+		"InvalidFlag":   {args: []string{"-processing-deadline", "soon"}},
+		"UnknownKey":    {file: "bogus: 1\n"},
+		"InvalidFile":   {file: "limits: [1]\n"},
+		"MissingFile":   {args: []string{"-config", "missing.yaml"}},
+		"InvalidMode":   {args: []string{"-mode", "production"}},
+		"UnknownFilter": {args: []string{"-filters", "sharpen,emboss"}},
+		"NegativeLimit": {args: []string{"-max-pixels", "-1"}},
+		"ZeroBodySize":  {env: map[string]string{"MAX_BODY_SIZE": "0"}},
+		"NegativeRead":  {args: []string{"-read-timeout", "-1s"}},
+		"SlowHeaders":   {env: map[string]string{"READ_HEADER_TIMEOUT": "2m", "READ_TIMEOUT": "1m"}},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			args := tc.args
+			if tc.file != "" {
+				args = append(args, "-config", writeFile(t, tc.file))
+			}
+
+			_, _, err := Load(args, env(tc.env))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestConfig_Print(t *testing.T) {
+	cfg := Default()
+	cfg.Filters = []string{"sharpen"}
+	cfg.Cache.MaxAge = time.Hour
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, cfg.Print(buf))
+	assert.Contains(t, buf.String(), "max_age: 1h0m0s")
+
+	// The output can be used as a config file.
+	loaded, _, err := Load([]string{"-config", writeFile(t, buf.String())}, env(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, cfg, loaded)
+}
@@ -0,0 +1,47 @@
+package image
+
+import (
+	"context"
+	"image"
+	"testing"
+
+	"github.com/drew138/go-graphics/filters/kernels"
+)
+
+// The benchmarks compare the serial path with a pool of GOMAXPROCS workers
+// on a 4MP image, e.g. go test -run ^$ -bench Convolve -cpu 1,4,8 ./internal/image
+func benchmarkConvolution(b *testing.B, convolve func(*Pool, *image.RGBA)) {
+	img := noise(2048, 2048, 1)
+
+	pools := map[string]*Pool{"serial": nil, "parallel": NewPool(0)}
+	for _, name := range []string{"serial", "parallel"} {
+		pool := pools[name]
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(img.Pix)))
+			for i := 0; i < b.N; i++ {
+				convolve(pool, img)
+			}
+		})
+	}
+	pools["parallel"].Close()
+}
+
+func BenchmarkConvolveDirect(b *testing.B) {
+	benchmarkConvolution(b, func(p *Pool, img *image.RGBA) {
+		must(convolveDirect(context.Background(), p, img, kernels.Sharpen, defaultEdges))
+	})
+}
+
+func BenchmarkConvolveSeparable(b *testing.B) {
+	weights := GaussianWeights(5, 15)
+	benchmarkConvolution(b, func(p *Pool, img *image.RGBA) {
+		must(convolveSeparable(context.Background(), p, img, weights, weights, defaultEdges))
+	})
+}
+
+func BenchmarkConvolveFFT(b *testing.B) {
+	kernel := randomKernel(31, 1)
+	benchmarkConvolution(b, func(p *Pool, img *image.RGBA) {
+		must(convolveFFT(context.Background(), p, img, kernel, defaultEdges))
+	})
+}
@@ -0,0 +1,250 @@
+package image
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/drew138/go-graphics/filters/kernels"
+)
+
+type algorithm int
+
+const (
+	algorithmDirect algorithm = iota
+	algorithmFFT
+)
+
+// fftCostFactor weighs one sample of an FFT convolution, which involves
+// complex arithmetic and scattered memory accesses, against one
+// multiply-add of a direct convolution.
+const fftCostFactor = 6
+
+// separabilityTolerance is the largest difference, relative to the largest
+// weight, tolerated between a kernel and the outer product its separable
+// form stands for.
+const separabilityTolerance = 1e-6
+
+// convolveImage applies kernel to img, treating its rows as image rows.
+// Rank-1 kernels are applied as two 1D passes, and other kernels either
+// directly or through FFTs, whichever is estimated to be cheaper for the
+// size of the kernel and the image. Edges are handled as e tells, and the
+// alpha channel is left untouched. The work is split in bands of rows
+// processed concurrently by the pool; every pixel is computed the same way
+// regardless of the banding, so the result does not depend on the number
+// of workers. The work stops early once ctx is done, in which case its
+// error is returned.
+func convolveImage(ctx context.Context, pool *Pool, img image.Image, kernel kernels.Kernel, e edges) (*image.RGBA, error) {
+	return convolveEdges(ctx, pool, img, len(kernel[0])/2, len(kernel)/2, e, func(src *image.RGBA, e edges) (*image.RGBA, error) {
+		if horizontal, vertical, ok := separate(kernel); ok {
+			return convolveSeparable(ctx, pool, src, horizontal, vertical, e)
+		}
+
+		bounds := src.Bounds()
+		switch chooseAlgorithm(len(kernel), bounds.Dx(), bounds.Dy()) {
+		case algorithmFFT:
+			return convolveFFT(ctx, pool, src, kernel, e)
+		default:
+			return convolveDirect(ctx, pool, src, kernel, e)
+		}
+	})
+}
+
+// chooseAlgorithm estimates the cost of convolving a width x height image
+// with a non separable size x size kernel, directly and through FFTs.
+func chooseAlgorithm(size, width, height int) algorithm {
+	direct := float64(width) * float64(height) * float64(size*size)
+
+	p := fftTileSize(size)
+	t := p - size + 1
+	tiles := math.Ceil(float64(width)/float64(t)) * math.Ceil(float64(height)/float64(t))
+	// Two forward and two inverse 2D transforms per tile, each taking
+	// 2*p*log2(p) operations per row or column.
+	fft := tiles * 4 * 2 * float64(p*p) * math.Log2(float64(p)) * fftCostFactor
+
+	if fft < direct {
+		return algorithmFFT
+	}
+	return algorithmDirect
+}
+
+// separate factors kernel into the horizontal and vertical 1D kernels
+// whose outer product it is, if it has rank 1.
+func separate(kernel kernels.Kernel) ([]float32, []float32, bool) {
+	// The largest weight is used as pivot to keep the division stable.
+	pi, pj, peak := 0, 0, float32(0)
+	for i, row := range kernel {
+		for j, v := range row {
+			if abs(v) > abs(peak) {
+				pi, pj, peak = i, j, v
+			}
+		}
+	}
+	if peak == 0 {
+		return nil, nil, false
+	}
+
+	horizontal := make([]float32, len(kernel[pi]))
+	for j, v := range kernel[pi] {
+		horizontal[j] = v / peak
+	}
+	vertical := make([]float32, len(kernel))
+	for i, row := range kernel {
+		vertical[i] = row[pj]
+	}
+
+	tolerance := separabilityTolerance * abs(peak)
+	for i, row := range kernel {
+		for j, v := range row {
+			if abs(v-vertical[i]*horizontal[j]) > tolerance {
+				return nil, nil, false
+			}
+		}
+	}
+
+	return horizontal, vertical, true
+}
+
+// convolveDirect computes every output pixel as the weighted sum of its
+// neighbourhood, taking size*size samples per pixel.
+func convolveDirect(ctx context.Context, pool *Pool, src *image.RGBA, kernel kernels.Kernel, e edges) (*image.RGBA, error) {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	ry, rx := len(kernel)/2, len(kernel[0])/2
+	xs, ys := e.indices(width, rx), e.indices(height, ry)
+
+	// Bands read the rows around them from the shared source, so the
+	// overlap required by the kernel needs no copying.
+	dst := image.NewRGBA(bounds)
+	err := pool.rows(ctx, height, func(y0, y1 int) {
+		for y := y0; y < y1 && ctx.Err() == nil; y++ {
+			for x := 0; x < width; x++ {
+				var r, g, b float32
+				for i, row := range kernel {
+					line := src.Pix[ys[y+i]*src.Stride:]
+					for j, w := range row {
+						o := xs[x+j] * 4
+						r += float32(line[o]) * w
+						g += float32(line[o+1]) * w
+						b += float32(line[o+2]) * w
+					}
+				}
+				setPixel(dst, src, x, y, r, g, b)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dst, nil
+}
+
+// convolveSeparable applies the kernel formed by the outer product of
+// vertical and horizontal as a horizontal pass followed by a vertical one,
+// which takes len(horizontal)+len(vertical) samples per pixel instead of
+// their product.
+func convolveSeparable(ctx context.Context, pool *Pool, img image.Image, horizontal, vertical []float32, e edges) (*image.RGBA, error) {
+	src := toRGBA(img)
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	rx, ry := len(horizontal)/2, len(vertical)/2
+	xs, ys := e.indices(width, rx), e.indices(height, ry)
+
+	// The horizontal pass keeps full precision for the vertical one, which
+	// only starts once every row is done since bands read their neighbours.
+	tmp := make([]float32, width*height*3)
+	err := pool.rows(ctx, height, func(y0, y1 int) {
+		for y := y0; y < y1 && ctx.Err() == nil; y++ {
+			line := src.Pix[y*src.Stride:]
+			for x := 0; x < width; x++ {
+				var r, g, b float32
+				for j, w := range horizontal {
+					o := xs[x+j] * 4
+					r += float32(line[o]) * w
+					g += float32(line[o+1]) * w
+					b += float32(line[o+2]) * w
+				}
+				o := (y*width + x) * 3
+				tmp[o], tmp[o+1], tmp[o+2] = r, g, b
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dst := image.NewRGBA(bounds)
+	err = pool.rows(ctx, height, func(y0, y1 int) {
+		for y := y0; y < y1 && ctx.Err() == nil; y++ {
+			for x := 0; x < width; x++ {
+				var r, g, b float32
+				for i, w := range vertical {
+					o := (ys[y+i]*width + x) * 3
+					r += tmp[o] * w
+					g += tmp[o+1] * w
+					b += tmp[o+2] * w
+				}
+				setPixel(dst, src, x, y, r, g, b)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dst, nil
+}
+
+// setPixel stores the convolved color channels of (x, y) in dst, along
+// with the alpha of the source pixel.
+func setPixel(dst, src *image.RGBA, x, y int, r, g, b float32) {
+	o := y*dst.Stride + x*4
+	a := src.Pix[y*src.Stride+x*4+3]
+	dst.Pix[o] = toChannel(r, a)
+	dst.Pix[o+1] = toChannel(g, a)
+	dst.Pix[o+2] = toChannel(b, a)
+	dst.Pix[o+3] = a
+}
+
+// toRGBA returns img as an *image.RGBA whose bounds start at the origin,
+// converting it if needed.
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+func clamp(i, n int) int {
+	if i < 0 {
+		return 0
+	}
+	if i >= n {
+		return n - 1
+	}
+	return i
+}
+
+func abs(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// toChannel rounds a premultiplied channel value, keeping it within
+// [0, alpha] so the result is a valid premultiplied color.
+func toChannel(v float32, alpha uint8) uint8 {
+	if v <= 0 {
+		return 0
+	}
+	if v >= float32(alpha) {
+		return alpha
+	}
+	return uint8(v + 0.5)
+}
@@ -0,0 +1,150 @@
+package image
+
+import (
+	"context"
+	"image"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+
+	"github.com/drew138/go-graphics/filters/kernels"
+	"github.com/stretchr/testify/assert"
+)
+
+func noise(width, height int, seed int64) *image.RGBA {
+	rnd := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(rnd.Intn(256))
+		if i%4 == 3 {
+			img.Pix[i] = 255
+		}
+	}
+	return img
+}
+
+func randomKernel(size int, seed int64) kernels.Kernel {
+	rnd := rand.New(rand.NewSource(seed))
+	kernel := make(kernels.Kernel, size)
+	for i := range kernel {
+		kernel[i] = make([]float32, size)
+		for j := range kernel[i] {
+			kernel[i][j] = rnd.Float32()*2 - 0.9
+		}
+	}
+	return kernel
+}
+
+func assertImagesClose(t *testing.T, expected, actual *image.RGBA, delta int) {
+	t.Helper()
+	assert.Equal(t, expected.Bounds(), actual.Bounds())
+	for i := range expected.Pix {
+		if d := int(expected.Pix[i]) - int(actual.Pix[i]); d > delta || d < -delta {
+			t.Fatalf("pixel byte %d differs: expected %d, got %d", i, expected.Pix[i], actual.Pix[i])
+		}
+	}
+}
+
+// must unwraps the result of a convolution that is not canceled.
+func must(img *image.RGBA, err error) *image.RGBA {
+	if err != nil {
+		panic(err)
+	}
+	return img
+}
+
+func TestConvolveImage_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, kernel := range []kernels.Kernel{kernels.GaussianBlur, kernels.Sharpen, randomKernel(31, 1)} {
+		_, err := convolveImage(ctx, NewPool(2), noise(64, 64, 1), kernel, defaultEdges)
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+}
+
+func TestSeparate(t *testing.T) {
+	horizontal, vertical, ok := separate(kernels.GaussianBlur)
+	assert.True(t, ok)
+	for i := range vertical {
+		for j := range horizontal {
+			assert.InDelta(t, kernels.GaussianBlur[i][j], vertical[i]*horizontal[j], 1e-7)
+		}
+	}
+
+	_, _, ok = separate(kernels.BoxBlur)
+	assert.True(t, ok)
+	_, _, ok = separate(kernels.Sharpen)
+	assert.False(t, ok)
+	_, _, ok = separate(kernels.EdgeDetection)
+	assert.False(t, ok)
+}
+
+func TestConvolveDirect_Orientation(t *testing.T) {
+	img := noise(8, 8, 1)
+
+	// Weighting the pixel to the left shifts the image right, and the
+	// pixel above shifts it down.
+	right := must(convolveDirect(context.Background(), nil, img, kernels.Kernel{{0, 0, 0}, {1, 0, 0}, {0, 0, 0}}, defaultEdges))
+	down := must(convolveDirect(context.Background(), nil, img, kernels.Kernel{{0, 1, 0}, {0, 0, 0}, {0, 0, 0}}, defaultEdges))
+
+	for y := 1; y < 8; y++ {
+		for x := 1; x < 8; x++ {
+			assert.Equal(t, img.RGBAAt(x-1, y), right.RGBAAt(x, y))
+			assert.Equal(t, img.RGBAAt(x, y-1), down.RGBAAt(x, y))
+		}
+	}
+}
+
+func TestConvolveFFT_MatchesDirect(t *testing.T) {
+	for _, size := range []int{3, 7, 17} {
+		img := noise(150, 90, int64(size))
+		kernel := randomKernel(size, int64(size))
+
+		assertImagesClose(t, must(convolveDirect(context.Background(), nil, img, kernel, defaultEdges)), must(convolveFFT(context.Background(), nil, img, kernel, defaultEdges)), 1)
+	}
+}
+
+func TestConvolveImage_SeparableMatchesDirect(t *testing.T) {
+	img := noise(40, 30, 2)
+	weights := GaussianWeights(2, 6)
+	kernel := make(kernels.Kernel, len(weights))
+	for i := range kernel {
+		kernel[i] = make([]float32, len(weights))
+		for j := range kernel[i] {
+			kernel[i][j] = weights[i] * weights[j] * 3
+		}
+	}
+
+	assertImagesClose(t, must(convolveDirect(context.Background(), nil, img, kernel, defaultEdges)), must(convolveImage(context.Background(), nil, img, kernel, defaultEdges)), 1)
+}
+
+func TestConvolveImage_SubImage(t *testing.T) {
+	img := noise(20, 20, 3)
+	sub := img.SubImage(image.Rect(5, 5, 15, 15))
+
+	out := must(convolveImage(context.Background(), nil, sub, kernels.Sharpen, defaultEdges))
+
+	assert.Equal(t, image.Rect(0, 0, 10, 10), out.Bounds())
+}
+
+func TestChooseAlgorithm(t *testing.T) {
+	assert.Equal(t, algorithmDirect, chooseAlgorithm(3, 4000, 3000))
+	assert.Equal(t, algorithmDirect, chooseAlgorithm(31, 8, 8))
+	assert.Equal(t, algorithmFFT, chooseAlgorithm(31, 4000, 3000))
+}
+
+func TestFFT_RoundTrip(t *testing.T) {
+	data := make([]complex128, 64)
+	for i := range data {
+		data[i] = complex(float64(i%7), float64(i%3))
+	}
+	original := append([]complex128(nil), data...)
+
+	fft2D(data, 8, false)
+	fft2D(data, 8, true)
+
+	for i := range data {
+		assert.InDelta(t, 0, cmplx.Abs(data[i]-original[i]), 1e-9)
+	}
+}
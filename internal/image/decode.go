@@ -0,0 +1,128 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+)
+
+const (
+	// DefaultMaxWidth and DefaultMaxHeight bound the sides of the images
+	// accepted for decoding unless configured otherwise.
+	DefaultMaxWidth  = 16384
+	DefaultMaxHeight = 16384
+	// DefaultMaxPixels bounds the area of the images accepted for decoding,
+	// which at 4 bytes per pixel keeps a decoded image within 200MB.
+	DefaultMaxPixels = 50_000_000
+)
+
+// Reasons reported by LimitError.
+const (
+	ReasonWidthExceeded  = "width_exceeded"
+	ReasonHeightExceeded = "height_exceeded"
+	ReasonPixelsExceeded = "pixels_exceeded"
+)
+
+// Limits bounds the dimensions of the images accepted for decoding. A
+// non-positive limit is not enforced.
+type Limits struct {
+	MaxWidth  int
+	MaxHeight int
+	MaxPixels int
+}
+
+// DefaultLimits returns the limits used unless configured otherwise.
+func DefaultLimits() Limits {
+	return Limits{
+		MaxWidth:  DefaultMaxWidth,
+		MaxHeight: DefaultMaxHeight,
+		MaxPixels: DefaultMaxPixels,
+	}
+}
+
+// LimitError reports an image whose dimensions exceed the Limits it was
+// decoded with.
+type LimitError struct {
+	Reason string
+	Width  int
+	Height int
+}
+
+func (e *LimitError) Error() string {
+	var limit string
+	switch e.Reason {
+	case ReasonWidthExceeded:
+		limit = "maximum width"
+	case ReasonHeightExceeded:
+		limit = "maximum height"
+	default:
+		limit = "maximum number of pixels"
+	}
+	return fmt.Sprintf("image of %dx%d pixels exceeds the %s", e.Width, e.Height, limit)
+}
+
+// Check returns a *LimitError if an image of the given dimensions exceeds
+// the limits.
+func (l Limits) Check(width, height int) error {
+	switch {
+	case l.MaxWidth > 0 && width > l.MaxWidth:
+		return &LimitError{ReasonWidthExceeded, width, height}
+	case l.MaxHeight > 0 && height > l.MaxHeight:
+		return &LimitError{ReasonHeightExceeded, width, height}
+	case l.MaxPixels > 0 && int64(width)*int64(height) > int64(l.MaxPixels):
+		return &LimitError{ReasonPixelsExceeded, width, height}
+	}
+	return nil
+}
+
+// Header holds the dimensions and format of an image read ahead of its
+// pixels, so that they can be checked before paying for a full decode.
+type Header struct {
+	image.Config
+	Format string
+
+	r io.Reader
+}
+
+// Pixels returns the number of pixels of the image.
+func (h *Header) Pixels() int64 {
+	return int64(h.Width) * int64(h.Height)
+}
+
+// Decode decodes the pixels of the image. It must be called at most once.
+func (h *Header) Decode() (image.Image, error) {
+	img, _, err := image.Decode(h.r)
+	return img, err
+}
+
+// ReadHeader reads the header of the image in r and checks the dimensions
+// it declares against limits, so that a small file declaring huge
+// dimensions is rejected before any memory is allocated for its pixels.
+func ReadHeader(r io.Reader, limits Limits) (*Header, error) {
+	// The bytes read by DecodeConfig are replayed for the full decode.
+	var header bytes.Buffer
+	config, format, err := image.DecodeConfig(io.TeeReader(r, &header))
+	if err != nil {
+		return nil, err
+	}
+	if err := limits.Check(config.Width, config.Height); err != nil {
+		return nil, err
+	}
+
+	return &Header{Config: config, Format: format, r: io.MultiReader(&header, r)}, nil
+}
+
+// Decode decodes an image after checking its dimensions against limits.
+func Decode(r io.Reader, limits Limits) (image.Image, string, error) {
+	header, err := ReadHeader(r, limits)
+	if err != nil {
+		return nil, "", err
+	}
+
+	img, err := header.Decode()
+	if err != nil {
+		return nil, "", err
+	}
+	return img, header.Format, nil
+}
@@ -0,0 +1,65 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// bomb returns a PNG of a few bytes whose header declares the given
+// dimensions.
+func bomb(width, height uint32) []byte {
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	data := buf.Bytes()
+
+	// The IHDR chunk follows the 8 byte signature, its length and its type.
+	ihdr := data[16:29]
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestDecode(t *testing.T) {
+	buf := new(bytes.Buffer)
+	_ = png.Encode(buf, gradient(20, 10))
+
+	img, format, err := Decode(bytes.NewReader(buf.Bytes()), DefaultLimits())
+	assert.NoError(t, err)
+	assert.Equal(t, FormatPNG, format)
+	assert.Equal(t, image.Rect(0, 0, 20, 10), img.Bounds())
+}
+
+func TestDecode_Limits(t *testing.T) {
+	cases := []struct {
+		data   []byte
+		limits Limits
+		reason string
+	}{
+		{bomb(60000, 60000), DefaultLimits(), ReasonWidthExceeded},
+		{bomb(100, 60000), DefaultLimits(), ReasonHeightExceeded},
+		{bomb(10000, 10000), DefaultLimits(), ReasonPixelsExceeded},
+		{bomb(20, 10), Limits{MaxWidth: 10}, ReasonWidthExceeded},
+		{bomb(20, 10), Limits{MaxPixels: 199}, ReasonPixelsExceeded},
+	}
+	for _, tc := range cases {
+		_, _, err := Decode(bytes.NewReader(tc.data), tc.limits)
+
+		var limitErr *LimitError
+		if assert.True(t, errors.As(err, &limitErr), err) {
+			assert.Equal(t, tc.reason, limitErr.Reason)
+		}
+	}
+}
+
+func TestLimits_Check(t *testing.T) {
+	assert.NoError(t, Limits{}.Check(1<<20, 1<<20))
+	assert.NoError(t, Limits{MaxWidth: 10, MaxHeight: 10, MaxPixels: 100}.Check(10, 10))
+}
@@ -0,0 +1,157 @@
+package image
+
+import (
+	"context"
+	"image"
+	"strings"
+)
+
+// Edge modes of convolution filters, telling what stands for the pixels
+// beyond the edges of the image that the kernel reaches.
+const (
+	// EdgeClamp repeats the pixels of the edges: aaa|abcd|ddd.
+	EdgeClamp = "clamp"
+	// EdgeMirror reflects the image about its edges, without repeating
+	// them: cb|abcd|cb.
+	EdgeMirror = "mirror"
+	// EdgeWrap tiles the image, taking the pixels of the opposite edge:
+	// cd|abcd|ab.
+	EdgeWrap = "wrap"
+	// EdgeConstant surrounds the image with a single color, transparent
+	// unless given.
+	EdgeConstant = "constant"
+	// EdgeCrop keeps only the pixels whose neighbourhood lies within the
+	// image, which shrinks it by the size of the kernel minus one.
+	EdgeCrop = "crop"
+)
+
+// edges is how a convolution handles the edges of the image, along with the
+// premultiplied color of constant edges.
+type edges struct {
+	mode  string
+	color [4]uint8
+}
+
+var defaultEdges = edges{mode: EdgeClamp}
+
+// edgeParams returns the parameters selecting the edge mode of a
+// convolution filter.
+func edgeParams() []Param {
+	return []Param{
+		{Name: "edge", Type: ParamString, Description: "How the pixels beyond the edges of the image are taken.", Enum: []string{EdgeClamp, EdgeMirror, EdgeWrap, EdgeConstant, EdgeCrop}, Default: EdgeClamp},
+		{Name: "edge_color", Type: ParamString, Description: "Color of constant edges, as rrggbb or rrggbbaa hexadecimal. Transparent by default."},
+	}
+}
+
+// parseEdges parses the edge and edge_color arguments of a convolution
+// filter.
+func parseEdges(args map[string]string) (edges, error) {
+	e := defaultEdges
+	if raw := args["edge"]; raw != "" {
+		e.mode = strings.ToLower(raw)
+		switch e.mode {
+		case EdgeClamp, EdgeMirror, EdgeWrap, EdgeConstant, EdgeCrop:
+		default:
+			return edges{}, invalidArgument("edge must be one of %s, %s, %s, %s or %s", EdgeClamp, EdgeMirror, EdgeWrap, EdgeConstant, EdgeCrop)
+		}
+	}
+
+	if raw := args["edge_color"]; raw != "" {
+		if e.mode != EdgeConstant {
+			return edges{}, invalidArgument("edge_color requires the %s edge mode", EdgeConstant)
+		}
+		color, err := parseColor(raw)
+		if err != nil {
+			return edges{}, invalidArgument("edge_color must be a color such as ff0000 or ff000080")
+		}
+		for i, c := range color {
+			e.color[i] = uint8(c + 0.5)
+		}
+	}
+
+	return e, nil
+}
+
+// index maps the coordinate i, which may lie beyond the edges of a line of
+// n pixels, to the pixel standing for it. Constant and crop edges are
+// handled around the convolution, which clamps them.
+func (e edges) index(i, n int) int {
+	switch e.mode {
+	case EdgeMirror:
+		if n == 1 {
+			return 0
+		}
+		period := 2 * (n - 1)
+		if i = mod(i, period); i >= n {
+			i = period - i
+		}
+		return i
+	case EdgeWrap:
+		return mod(i, n)
+	default:
+		return clamp(i, n)
+	}
+}
+
+// indices maps the coordinates -radius to n+radius-1, shifted by radius,
+// to the pixels standing for them.
+func (e edges) indices(n, radius int) []int {
+	indices := make([]int, n+2*radius)
+	for i := range indices {
+		indices[i] = e.index(i-radius, n)
+	}
+	return indices
+}
+
+func mod(i, n int) int {
+	if i %= n; i < 0 {
+		i += n
+	}
+	return i
+}
+
+// convolveEdges applies convolve, a convolution by a kernel reaching rx
+// columns and ry rows around every pixel, to img with its edges handled as
+// e tells. Constant edges are obtained by padding the image with their
+// color and crop edges by dropping the pixels whose neighbourhood overflows
+// the image, so that convolve only ever indexes the pixels beyond the
+// edges.
+func convolveEdges(ctx context.Context, pool *Pool, img image.Image, rx, ry int, e edges, convolve func(src *image.RGBA, e edges) (*image.RGBA, error)) (*image.RGBA, error) {
+	src := toRGBA(img)
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+
+	switch e.mode {
+	case EdgeConstant:
+		out, err := convolve(pad(src, rx, ry, e.color), defaultEdges)
+		if err != nil {
+			return nil, err
+		}
+		return cropImage(ctx, pool, out, image.Rect(rx, ry, rx+w, ry+h))
+	case EdgeCrop:
+		if w <= 2*rx || h <= 2*ry {
+			return nil, invalidArgument("the %dx%d image is too small for the %dx%d kernel with %s edges", w, h, 2*rx+1, 2*ry+1, EdgeCrop)
+		}
+		out, err := convolve(src, defaultEdges)
+		if err != nil {
+			return nil, err
+		}
+		return cropImage(ctx, pool, out, image.Rect(rx, ry, w-rx, h-ry))
+	default:
+		return convolve(src, e)
+	}
+}
+
+// pad returns src surrounded by rx columns and ry rows of color.
+func pad(src *image.RGBA, rx, ry int, color [4]uint8) *image.RGBA {
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w+2*rx, h+2*ry))
+	if color != [4]uint8{} {
+		for i := 0; i < len(dst.Pix); i += 4 {
+			copy(dst.Pix[i:i+4], color[:])
+		}
+	}
+	for y := 0; y < h; y++ {
+		copy(dst.Pix[(y+ry)*dst.Stride+rx*4:], src.Pix[y*src.Stride:y*src.Stride+w*4])
+	}
+	return dst
+}
@@ -0,0 +1,171 @@
+package image
+
+import (
+	"context"
+	"flag"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/drew138/go-graphics/filters/kernels"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden images of the tests")
+
+func TestEdges_Indices(t *testing.T) {
+	cases := map[string][]int{
+		EdgeClamp:    {0, 0, 0, 1, 2, 3, 3, 3},
+		EdgeMirror:   {2, 1, 0, 1, 2, 3, 2, 1},
+		EdgeWrap:     {2, 3, 0, 1, 2, 3, 0, 1},
+		EdgeConstant: {0, 0, 0, 1, 2, 3, 3, 3},
+	}
+	for mode, indices := range cases {
+		assert.Equal(t, indices, edges{mode: mode}.indices(4, 2), mode)
+	}
+
+	// Kernels may reach further than the image is wide.
+	assert.Equal(t, []int{1, 0, 1, 0, 1, 0, 1, 0}, edges{mode: EdgeMirror}.indices(2, 3))
+	assert.Equal(t, []int{0, 0, 0, 0, 0}, edges{mode: EdgeMirror}.indices(1, 2))
+	assert.Equal(t, []int{0, 1, 0, 1, 0, 1}, edges{mode: EdgeWrap}.indices(2, 2))
+}
+
+func TestParseEdges(t *testing.T) {
+	e, err := parseEdges(map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, defaultEdges, e)
+
+	e, err = parseEdges(map[string]string{"edge": "Constant", "edge_color": "ff000080"})
+	assert.NoError(t, err)
+	assert.Equal(t, edges{mode: EdgeConstant, color: [4]uint8{128, 0, 0, 128}}, e)
+
+	for _, args := range []map[string]string{
+		{"edge": "reflect"},
+		{"edge_color": "ff0000"},
+		{"edge": EdgeConstant, "edge_color": "red"},
+	} {
+		_, err := parseEdges(args)
+		assert.ErrorIs(t, err, ErrInvalidArgument, args)
+	}
+}
+
+// A kernel taking the pixel on the right shows which pixel stands for the
+// one beyond the right edge.
+func TestEdges_Modes(t *testing.T) {
+	src := gradient(6, 4)
+	right := kernels.Kernel{{0, 0, 0}, {0, 0, 1}, {0, 0, 0}}
+	cases := map[string]color.Color{
+		EdgeClamp:    src.At(5, 2),
+		EdgeMirror:   src.At(4, 2),
+		EdgeWrap:     src.At(0, 2),
+		EdgeConstant: color.RGBA{0, 0, 255, 255},
+	}
+	for mode, beyond := range cases {
+		args := map[string]string{"kernel": "[[0,0,0],[0,0,1],[0,0,0]]", "edge": mode}
+		if mode == EdgeConstant {
+			args["edge_color"] = "0000ff"
+		}
+		out, err := compileStep(t, "custom", args)(context.Background(), nil, src)
+
+		assert.NoError(t, err)
+		assert.Equal(t, src.Bounds(), out.Bounds(), mode)
+		assert.Equal(t, src.At(3, 2), out.At(2, 2), mode)
+		assert.Equal(t, beyond, out.At(5, 2), mode)
+	}
+
+	out, err := convolveImage(context.Background(), nil, src, right, edges{mode: EdgeCrop})
+	assert.NoError(t, err)
+	assert.Equal(t, image.Pt(4, 2), out.Rect.Size())
+	assert.Equal(t, src.At(2, 1), out.At(0, 0))
+
+	_, err = convolveImage(context.Background(), nil, gradient(2, 8), right, edges{mode: EdgeCrop})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestEdges_Path(t *testing.T) {
+	transformation, err := ParsePath("blur:2::constant:ffffff/sharpen:mirror/cat.png")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"sigma": "2", "radius": "", "edge": "constant", "edge_color": "ffffff"}, transformation.Operations[0].Args)
+	assert.Equal(t, "gaussianblur:2::constant:ffffff/sharpen:mirror/cat.png", transformation.String())
+}
+
+// Every edge mode is checked against a golden image, rewritten with
+// go test -run TestEdges_Golden -update, and every algorithm must agree
+// with it.
+func TestEdges_Golden(t *testing.T) {
+	src := gradient(16, 12)
+	horizontal := []float32{1. / 15, 2. / 15, 3. / 15, 4. / 15, 5. / 15}
+	vertical := []float32{5. / 15, 4. / 15, 3. / 15, 2. / 15, 1. / 15}
+	kernel := make(kernels.Kernel, len(vertical))
+	for i, v := range vertical {
+		for _, h := range horizontal {
+			kernel[i] = append(kernel[i], v*h)
+		}
+	}
+
+	ctx := context.Background()
+	algorithms := map[string]func(src *image.RGBA, e edges) (*image.RGBA, error){
+		"separable": func(src *image.RGBA, e edges) (*image.RGBA, error) {
+			return convolveSeparable(ctx, nil, src, horizontal, vertical, e)
+		},
+		"fft": func(src *image.RGBA, e edges) (*image.RGBA, error) {
+			return convolveFFT(ctx, NewPool(2), src, kernel, e)
+		},
+	}
+
+	modes := map[string]edges{
+		EdgeClamp:    {mode: EdgeClamp},
+		EdgeMirror:   {mode: EdgeMirror},
+		EdgeWrap:     {mode: EdgeWrap},
+		EdgeConstant: {mode: EdgeConstant, color: [4]uint8{0, 0, 128, 128}},
+		EdgeCrop:     {mode: EdgeCrop},
+	}
+	for mode, e := range modes {
+		golden := filepath.Join("testdata", "edges", mode+".png")
+		out, err := convolveEdges(ctx, nil, src, 2, 2, e, func(src *image.RGBA, e edges) (*image.RGBA, error) {
+			return convolveDirect(ctx, nil, src, kernel, e)
+		})
+		assert.NoError(t, err, mode)
+
+		if *update {
+			assert.NoError(t, writePNG(golden, out))
+		}
+		expected := toRGBA(readPNG(t, golden))
+		assert.Equal(t, expected.Pix, out.Pix, mode)
+
+		for name, algorithm := range algorithms {
+			out, err := convolveEdges(ctx, nil, src, 2, 2, e, algorithm)
+			assert.NoError(t, err, "%s with %s", mode, name)
+			assertImagesClose(t, expected, out, 1)
+		}
+	}
+}
+
+func writePNG(path string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+func readPNG(t *testing.T, path string) image.Image {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("missing golden image, run go test -update: %v", err)
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("failed to decode %s: %v", path, err)
+	}
+	return img
+}
@@ -0,0 +1,133 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+)
+
+// exifOrientationTag is the tag of the orientation in the first IFD of the
+// EXIF metadata.
+const exifOrientationTag = 0x0112
+
+var (
+	exifHeader   = []byte("Exif\x00\x00")
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// ExifOrientation returns the EXIF orientation of a jpeg or png file,
+// from 1 to 8, which tells how the decoded pixels must be transformed to
+// be displayed upright. Files without a valid orientation get 1, which
+// leaves them as they are.
+func ExifOrientation(data []byte) int {
+	var tiff []byte
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		tiff = jpegExif(data[2:])
+	case bytes.HasPrefix(data, pngSignature):
+		tiff = pngExif(data[len(pngSignature):])
+	}
+
+	if orientation := tiffOrientation(tiff); orientation >= 1 && orientation <= 8 {
+		return orientation
+	}
+	return 1
+}
+
+// jpegExif returns the TIFF structure of the APP1 segment of a jpeg file
+// holding EXIF metadata, which precedes the image data.
+func jpegExif(data []byte) []byte {
+	for len(data) >= 4 && data[0] == 0xff {
+		marker := data[1]
+		// Markers without a length, and fill bytes.
+		if marker == 0xff || marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7) {
+			data = data[1:]
+			if marker != 0xff {
+				data = data[1:]
+			}
+			continue
+		}
+		// The image data starts at the start of scan.
+		if marker == 0xda {
+			return nil
+		}
+
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if length < 2 || len(data) < 2+length {
+			return nil
+		}
+		segment := data[4 : 2+length]
+		if marker == 0xe1 && bytes.HasPrefix(segment, exifHeader) {
+			return segment[len(exifHeader):]
+		}
+		data = data[2+length:]
+	}
+	return nil
+}
+
+// pngExif returns the contents of the eXIf chunk of a png file.
+func pngExif(data []byte) []byte {
+	for len(data) >= 12 {
+		length := int(binary.BigEndian.Uint32(data[:4]))
+		kind := string(data[4:8])
+		if length < 0 || len(data) < 12+length || kind == "IEND" {
+			return nil
+		}
+		if kind == "eXIf" {
+			return data[8 : 8+length]
+		}
+		data = data[12+length:]
+	}
+	return nil
+}
+
+// tiffOrientation returns the orientation tag of the first IFD of a TIFF
+// structure, or 0 if it has none.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	if order.Uint16(tiff[2:4]) != 42 {
+		return 0
+	}
+
+	ifd := int64(order.Uint32(tiff[4:8]))
+	if ifd+2 > int64(len(tiff)) {
+		return 0
+	}
+	entries := tiff[ifd+2:]
+	for i := 0; i < int(order.Uint16(tiff[ifd:])) && len(entries) >= 12; i++ {
+		entry := entries[:12]
+		entries = entries[12:]
+		// The orientation is a single SHORT, stored in the value field.
+		if order.Uint16(entry[:2]) == exifOrientationTag && order.Uint16(entry[2:4]) == 3 {
+			return int(order.Uint16(entry[8:10]))
+		}
+	}
+	return 0
+}
+
+// Orient returns img transformed as the EXIF orientation tells for it to
+// be displayed upright, copying its rows on pool. Images with an
+// orientation of 1, or an invalid one, are returned as they are. The copy
+// stops early once ctx is done, in which case its error is returned.
+func Orient(ctx context.Context, pool *Pool, img image.Image, orientation int) (image.Image, error) {
+	if orientation <= 1 || orientation > 8 {
+		return img, nil
+	}
+	oriented, err := orient(ctx, pool, toRGBA(img), orientation)
+	if err != nil {
+		return nil, err
+	}
+	return oriented, nil
+}
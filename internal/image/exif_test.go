@@ -0,0 +1,101 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// exifTIFF returns EXIF metadata holding a single orientation tag.
+func exifTIFF(order binary.AppendByteOrder, orientation uint16) []byte {
+	tiff := []byte("MM")
+	if order == binary.LittleEndian {
+		tiff = []byte("II")
+	}
+	tiff = order.AppendUint16(tiff, 42)
+	tiff = order.AppendUint32(tiff, 8)
+	tiff = order.AppendUint16(tiff, 1)
+	tiff = order.AppendUint16(tiff, exifOrientationTag)
+	tiff = order.AppendUint16(tiff, 3)
+	tiff = order.AppendUint32(tiff, 1)
+	tiff = order.AppendUint16(tiff, orientation)
+	tiff = order.AppendUint16(tiff, 0)
+	return order.AppendUint32(tiff, 0)
+}
+
+// withJPEGExif inserts an APP1 segment holding tiff after the start of
+// image of a jpeg file.
+func withJPEGExif(data, tiff []byte) []byte {
+	segment := append(append([]byte{}, exifHeader...), tiff...)
+	out := append([]byte{0xff, 0xd8, 0xff, 0xe1}, binary.BigEndian.AppendUint16(nil, uint16(len(segment)+2))...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// withPNGExif inserts an eXIf chunk holding tiff after the header of a png
+// file.
+func withPNGExif(data, tiff []byte) []byte {
+	const ihdrEnd = 8 + 25
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(tiff)))
+	chunk = append(append(chunk, "eXIf"...), tiff...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	out := append(append([]byte{}, data[:ihdrEnd]...), chunk...)
+	return append(out, data[ihdrEnd:]...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	var jpegFile, pngFile bytes.Buffer
+	assert.NoError(t, jpeg.Encode(&jpegFile, gradient(4, 2), nil))
+	assert.NoError(t, png.Encode(&pngFile, gradient(4, 2)))
+
+	assert.Equal(t, 1, ExifOrientation(jpegFile.Bytes()))
+	assert.Equal(t, 1, ExifOrientation(pngFile.Bytes()))
+
+	for _, order := range []binary.AppendByteOrder{binary.BigEndian, binary.LittleEndian} {
+		data := withJPEGExif(jpegFile.Bytes(), exifTIFF(order, 6))
+		assert.Equal(t, 6, ExifOrientation(data), order)
+
+		// The file must still decode.
+		_, _, err := Decode(bytes.NewReader(data), DefaultLimits())
+		assert.NoError(t, err)
+	}
+
+	data := withPNGExif(pngFile.Bytes(), exifTIFF(binary.BigEndian, 8))
+	assert.Equal(t, 8, ExifOrientation(data))
+	_, _, err := Decode(bytes.NewReader(data), DefaultLimits())
+	assert.NoError(t, err)
+
+	// Invalid orientations and truncated metadata leave images as they are.
+	assert.Equal(t, 1, ExifOrientation(withJPEGExif(jpegFile.Bytes(), exifTIFF(binary.BigEndian, 9))))
+	assert.Equal(t, 1, ExifOrientation(withJPEGExif(jpegFile.Bytes(), exifTIFF(binary.BigEndian, 6)[:12])))
+	assert.Equal(t, 1, ExifOrientation([]byte{0xff, 0xd8, 0xff, 0xe1, 0xff}))
+	assert.Equal(t, 1, ExifOrientation(nil))
+}
+
+func TestOrient_Exported(t *testing.T) {
+	src := gradient(4, 2)
+	ctx := context.Background()
+	for _, orientation := range []int{0, 1, 9} {
+		out, err := Orient(ctx, nil, src, orientation)
+		assert.NoError(t, err)
+		assert.Same(t, src, out, orientation)
+	}
+
+	out, err := Orient(ctx, NewPool(2), src, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, out.Bounds().Dx())
+	assert.Equal(t, 4, out.Bounds().Dy())
+	assert.Equal(t, src.At(0, 1), out.At(0, 0))
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = Orient(canceled, NewPool(2), gradient(64, 64), 6)
+	assert.ErrorIs(t, err, context.Canceled)
+}
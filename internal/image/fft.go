@@ -0,0 +1,157 @@
+package image
+
+import (
+	"context"
+	"image"
+	"math"
+	"math/bits"
+	"math/cmplx"
+
+	"github.com/drew138/go-graphics/filters/kernels"
+)
+
+// minFFTTileSize is the smallest side of the tiles FFT convolutions are
+// computed on.
+const minFFTTileSize = 64
+
+// fftTileSize returns the side of the square tiles used to convolve with a
+// size x size kernel: a power of two large enough for most of each tile to
+// be valid output despite the size-1 pixels of overlap.
+func fftTileSize(size int) int {
+	n := max(4*size, minFFTTileSize)
+	return 1 << bits.Len(uint(n-1))
+}
+
+// convolveFFT convolves src with kernel using the overlap-save method: the
+// image is split in tiles that are transformed, multiplied by the spectrum
+// of the kernel and transformed back, which costs O(log p) per pixel
+// regardless of the size of the kernel. The red and green channels are
+// packed in the real and imaginary parts of one transform, which works
+// because the kernel is real.
+func convolveFFT(ctx context.Context, pool *Pool, src *image.RGBA, kernel kernels.Kernel, e edges) (*image.RGBA, error) {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	size := len(kernel)
+	radius := size / 2
+	p := fftTileSize(size)
+	t := p - size + 1
+
+	spectrum := kernelSpectrum(kernel, p)
+	xs, ys := e.indices(width, radius+p), e.indices(height, radius+p)
+
+	// Every row of tiles is a task. The tiling does not depend on the
+	// number of workers, so neither does the result.
+	dst := image.NewRGBA(bounds)
+	err := pool.run(ctx, (height+t-1)/t, func(row int) {
+		ty := row * t
+		rg := make([]complex128, p*p)
+		b := make([]complex128, p*p)
+
+		for tx := 0; tx < width && ctx.Err() == nil; tx += t {
+			for i := 0; i < p; i++ {
+				line := src.Pix[ys[ty+i+p]*src.Stride:]
+				for j := 0; j < p; j++ {
+					o := xs[tx+j+p] * 4
+					rg[i*p+j] = complex(float64(line[o]), float64(line[o+1]))
+					b[i*p+j] = complex(float64(line[o+2]), 0)
+				}
+			}
+
+			fft2D(rg, p, false)
+			fft2D(b, p, false)
+			for i, s := range spectrum {
+				rg[i] *= s
+				b[i] *= s
+			}
+			fft2D(rg, p, true)
+			fft2D(b, p, true)
+
+			// With the flipped kernel at the origin, the output for
+			// (tx+x, ty+y) lands at (x+size-1, y+size-1), past the samples
+			// corrupted by the circular wrap around.
+			for y := 0; y < t && ty+y < height; y++ {
+				for x := 0; x < t && tx+x < width; x++ {
+					o := (y+size-1)*p + x + size - 1
+					setPixel(dst, src, tx+x, ty+y, float32(real(rg[o])), float32(imag(rg[o])), float32(real(b[o])))
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dst, nil
+}
+
+// kernelSpectrum returns the 2D transform of kernel flipped in both
+// directions and zero padded to p x p, so that multiplying by it computes
+// the same weighted sums as the direct convolution.
+func kernelSpectrum(kernel kernels.Kernel, p int) []complex128 {
+	size := len(kernel)
+	spectrum := make([]complex128, p*p)
+	for i, row := range kernel {
+		for j, w := range row {
+			spectrum[(size-1-i)*p+size-1-j] = complex(float64(w), 0)
+		}
+	}
+	fft2D(spectrum, p, false)
+	return spectrum
+}
+
+// fft2D transforms the p x p matrix data in place, one row and then one
+// column at a time. The inverse transform is scaled by 1/p².
+func fft2D(data []complex128, p int, inverse bool) {
+	for i := 0; i < p; i++ {
+		fft(data[i*p:(i+1)*p], inverse)
+	}
+
+	column := make([]complex128, p)
+	for j := 0; j < p; j++ {
+		for i := 0; i < p; i++ {
+			column[i] = data[i*p+j]
+		}
+		fft(column, inverse)
+		for i := 0; i < p; i++ {
+			data[i*p+j] = column[i]
+		}
+	}
+
+	if inverse {
+		scale := complex(1/float64(p*p), 0)
+		for i := range data {
+			data[i] *= scale
+		}
+	}
+}
+
+// fft is an in-place iterative radix-2 Cooley-Tukey transform of data,
+// whose length must be a power of two. The inverse transform is unscaled.
+func fft(data []complex128, inverse bool) {
+	n := len(data)
+	shift := 64 - bits.Len(uint(n-1))
+
+	for i := 0; i < n; i++ {
+		if j := int(bits.Reverse64(uint64(i)) >> shift); i < j {
+			data[i], data[j] = data[j], data[i]
+		}
+	}
+
+	sign := -1.0
+	if inverse {
+		sign = 1
+	}
+
+	for length := 2; length <= n; length <<= 1 {
+		step := cmplx.Rect(1, sign*2*math.Pi/float64(length))
+		for start := 0; start < n; start += length {
+			w := complex(1, 0)
+			for k := 0; k < length/2; k++ {
+				even, odd := data[start+k], data[start+k+length/2]*w
+				data[start+k] = even + odd
+				data[start+k+length/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"sort"
+
+	"github.com/drew138/go-graphics/filters/kernels"
+)
+
+// Types of filter parameters.
+const (
+	ParamInteger = "integer"
+	ParamNumber  = "number"
+	ParamString  = "string"
+	ParamBoolean = "boolean"
+	// ParamKernel is a convolution matrix written as a JSON list of rows.
+	ParamKernel = "kernel"
+)
+
+const (
+	// MaxOutputSide bounds the sides of the images filters produce.
+	MaxOutputSide = DefaultMaxWidth
+	// MaxOutputPixels bounds the area of the images filters produce, so
+	// that enlarging an image cannot allocate more than the largest image
+	// accepted for decoding.
+	MaxOutputPixels = DefaultMaxPixels
+)
+
+// Param describes an argument accepted by a filter.
+type Param struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	// Minimum and Maximum bound numeric arguments, when set.
+	Minimum *float64 `json:"minimum,omitempty"`
+	Maximum *float64 `json:"maximum,omitempty"`
+	// Enum lists the accepted values of string arguments, when limited.
+	Enum []string `json:"enum,omitempty"`
+	// Default is the value used when the argument is not given, if any.
+	Default any `json:"default,omitempty"`
+	// Header names the request header the argument may also be given in.
+	Header string `json:"header,omitempty"`
+}
+
+// Filter is an operation that can be applied to an image, either on its
+// own or as a step of a pipeline or transformation.
+type Filter struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Params lists the arguments of the filter, in the order they are
+	// written in transformation paths.
+	Params []Param `json:"params"`
+	// Kernel is the convolution matrix the filter applies, if it is fixed.
+	Kernel kernels.Kernel `json:"kernel,omitempty"`
+
+	compile func(args map[string]string) (step, error)
+}
+
+// checkOutput returns an error if an image of the given size would exceed
+// the maximum size of the images filters produce.
+func checkOutput(size image.Point) error {
+	if size.X > MaxOutputSide || size.Y > MaxOutputSide || size.X*size.Y > MaxOutputPixels {
+		return fmt.Errorf("%w: resulting image of %dx%d pixels exceeds the maximum size", ErrInvalidArgument, size.X, size.Y)
+	}
+	return nil
+}
+
+// bound returns a pointer to v, for the bounds of a Param.
+func bound(v float64) *float64 {
+	return &v
+}
+
+// accepts reports whether the filter takes an argument called name.
+func (f Filter) accepts(name string) bool {
+	for _, p := range f.Params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+var filters = map[string]Filter{}
+
+// register makes f available to every service, endpoint and discovery
+// document. Filters are registered by the init function of the file that
+// implements them.
+func register(f Filter) {
+	if _, exists := filters[f.Name]; exists {
+		panic(fmt.Sprintf("image: filter %q registered twice", f.Name))
+	}
+	filters[f.Name] = f
+}
+
+// LookupFilter returns the filter called name.
+func LookupFilter(name string) (Filter, bool) {
+	f, ok := filters[name]
+	return f, ok
+}
+
+// Filters returns the registered filters in alphabetical order.
+func Filters() []Filter {
+	list := make([]Filter, 0, len(filters))
+	for _, f := range filters {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Operations returns the names of the registered filters in alphabetical
+// order.
+func Operations() []string {
+	names := make([]string, 0, len(filters))
+	for _, f := range Filters() {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func init() {
+	register(kernelFilter("sharpen", "Sharpens the image with a 3x3 kernel.", kernels.Sharpen))
+	register(kernelFilter("edgedetection", "Highlights the edges of the image with a 3x3 kernel.", kernels.EdgeDetection))
+	register(kernelFilter("boxblur", "Blurs the image with a 3x3 box kernel.", kernels.BoxBlur))
+	register(Filter{
+		Name:        "custom",
+		Description: "Convolves the image with a custom kernel.",
+		Params: append([]Param{{
+			Name:        "kernel",
+			Type:        ParamKernel,
+			Description: fmt.Sprintf("Square matrix with an odd side of at most %d, such as [[0,-1,0],[-1,5,-1],[0,-1,0]], whose weights are within the bounds.", MaxKernelSize),
+			Required:    true,
+			Minimum:     bound(-MaxKernelWeight),
+			Maximum:     bound(MaxKernelWeight),
+			Header:      "X-Kernel",
+		}}, edgeParams()...),
+		compile: func(args map[string]string) (step, error) {
+			kernel, err := ParseKernel(args["kernel"])
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidArgument, err)
+			}
+			e, err := parseEdges(args)
+			if err != nil {
+				return nil, err
+			}
+			return convolve(kernel, e), nil
+		},
+	})
+}
+
+// kernelFilter returns a filter convolving images with a fixed kernel.
+func kernelFilter(name, description string, kernel kernels.Kernel) Filter {
+	return Filter{
+		Name:        name,
+		Description: description,
+		Params:      edgeParams(),
+		Kernel:      kernel,
+		compile: func(args map[string]string) (step, error) {
+			e, err := parseEdges(args)
+			if err != nil {
+				return nil, err
+			}
+			return convolve(kernel, e), nil
+		},
+	}
+}
+
+func convolve(kernel kernels.Kernel, e edges) step {
+	return func(ctx context.Context, pool *Pool, img image.Image) (image.Image, error) {
+		return convolveImage(ctx, pool, img, kernel, e)
+	}
+}
@@ -0,0 +1,31 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilters(t *testing.T) {
+	assert.Equal(t, []string{"boxblur", "crop", "custom", "edgedetection", "flip", "gaussianblur", "resize", "rotate", "sharpen"}, Operations())
+
+	for _, f := range Filters() {
+		assert.NotEmpty(t, f.Description, f.Name)
+		for _, p := range f.Params {
+			assert.Contains(t, []string{ParamInteger, ParamNumber, ParamString, ParamBoolean, ParamKernel}, p.Type, f.Name+"."+p.Name)
+		}
+	}
+
+	blur, ok := LookupFilter("gaussianblur")
+	assert.True(t, ok)
+	assert.True(t, blur.accepts("radius"))
+	assert.False(t, blur.accepts("kernel"))
+
+	_, ok = LookupFilter("emboss")
+	assert.False(t, ok)
+}
+
+func TestRegister_Twice(t *testing.T) {
+	sharpen, _ := LookupFilter("sharpen")
+	assert.Panics(t, func() { register(sharpen) })
+}
@@ -0,0 +1,193 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"mime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Format names as reported by image.Decode.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+	FormatBMP  = "bmp"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+// EncodeFunc writes img to w in a particular image format.
+type EncodeFunc func(w io.Writer, img image.Image, opts EncodeOptions) error
+
+type encoder struct {
+	mimeType string
+	encode   EncodeFunc
+}
+
+var (
+	encodersMu sync.RWMutex
+	encoders   = map[string]encoder{}
+)
+
+// mimeAliases maps non-standard media types some clients still send to the
+// format they refer to.
+var mimeAliases = map[string]string{
+	"image/jpg":      FormatJPEG,
+	"image/pjpeg":    FormatJPEG,
+	"image/x-bmp":    FormatBMP,
+	"image/x-ms-bmp": FormatBMP,
+}
+
+// formatAliases maps alternative spellings of format names to the
+// registered format.
+var formatAliases = map[string]string{
+	"jpg": FormatJPEG,
+}
+
+// decodable lists the formats with a decoder registered in the image
+// package, either by the standard library or by the imports above.
+var decodable = map[string]bool{
+	FormatJPEG: true,
+	FormatPNG:  true,
+	FormatGIF:  true,
+	FormatBMP:  true,
+}
+
+// RegisterEncoder makes format available as an output format, served with
+// the given media type. Registering an existing format replaces it.
+func RegisterEncoder(format, mimeType string, encode EncodeFunc) {
+	encodersMu.Lock()
+	defer encodersMu.Unlock()
+
+	encoders[format] = encoder{mimeType: mimeType, encode: encode}
+}
+
+// Formats returns the registered output formats in alphabetical order.
+func Formats() []string {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	formats := make([]string, 0, len(encoders))
+	for format := range encoders {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// InputFormats returns the formats images can be decoded from in
+// alphabetical order.
+func InputFormats() []string {
+	formats := make([]string, 0, len(decodable))
+	for format := range decodable {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// CanEncode reports whether an encoder is registered for format.
+func CanEncode(format string) bool {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	_, ok := encoders[format]
+	return ok
+}
+
+// ParseFormat resolves a user supplied format name, such as "PNG" or "jpg",
+// to a registered output format.
+func ParseFormat(name string) (string, bool) {
+	format := strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := formatAliases[format]; ok {
+		format = alias
+	}
+	return format, CanEncode(format)
+}
+
+// FormatFromContentType returns the image format for a supported request
+// Content-Type header.
+func FormatFromContentType(contentType string) (string, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	format, ok := formatForMediaType(mediaType)
+	return format, ok && decodable[format]
+}
+
+// FormatFromMediaType returns the output format served with the given media
+// type, if an encoder is registered for it.
+func FormatFromMediaType(mediaType string) (string, bool) {
+	format, ok := formatForMediaType(mediaType)
+	return format, ok && CanEncode(format)
+}
+
+func formatForMediaType(mediaType string) (string, bool) {
+	if format, ok := mimeAliases[mediaType]; ok {
+		return format, true
+	}
+
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	for format, enc := range encoders {
+		if enc.mimeType == mediaType {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// MIMEType returns the media type images of the given format are served as.
+func MIMEType(format string) string {
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	return encoders[format].mimeType
+}
+
+// encodeContext encodes img, giving up as soon as ctx is done.
+func encodeContext(ctx context.Context, img image.Image, opts EncodeOptions) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := encode(contextWriter{ctx, &buf}, img, opts); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// contextWriter fails writes once its context is done, which interrupts
+// encoders since they all write their output progressively.
+type contextWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (cw contextWriter) Write(p []byte) (int, error) {
+	if err := cw.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cw.w.Write(p)
+}
+
+func encode(w io.Writer, img image.Image, opts EncodeOptions) error {
+	encodersMu.RLock()
+	enc, ok := encoders[opts.Format]
+	encodersMu.RUnlock()
+
+	if !ok {
+		return ErrUnsupportedFormat
+	}
+	return enc.encode(w, img, opts)
+}
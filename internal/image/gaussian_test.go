@@ -0,0 +1,121 @@
+package image
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/drew138/go-graphics/filters/kernels"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGaussianWeights(t *testing.T) {
+	weights := GaussianWeights(1.5, 4)
+
+	assert.Len(t, weights, 9)
+	var sum float32
+	for i, w := range weights {
+		sum += w
+		assert.InDelta(t, w, weights[len(weights)-1-i], 1e-7)
+	}
+	assert.InDelta(t, 1, sum, 1e-5)
+	assert.Greater(t, weights[4], weights[3])
+}
+
+func TestConvolveSeparable_MatchesDirectConvolution(t *testing.T) {
+	img := gradient(24, 24)
+	weights := GaussianWeights(1, 1)
+
+	kernel := make(kernels.Kernel, len(weights))
+	for i := range weights {
+		kernel[i] = make([]float32, len(weights))
+		for j := range weights {
+			kernel[i][j] = weights[i] * weights[j]
+		}
+	}
+
+	separable := must(convolveSeparable(context.Background(), nil, img, weights, weights, defaultEdges))
+	direct := must(convolveDirect(context.Background(), nil, img, kernel, defaultEdges))
+
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 24; x++ {
+			s, d := separable.RGBAAt(x, y), direct.RGBAAt(x, y)
+			assert.InDelta(t, d.R, s.R, 1)
+			assert.InDelta(t, d.G, s.G, 1)
+			assert.InDelta(t, d.B, s.B, 1)
+		}
+	}
+}
+
+func TestConvolveSeparable_PreservesUniformImages(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 40, 80, 120, 255
+	}
+
+	out := must(convolveSeparable(context.Background(), nil, img, GaussianWeights(3, 9), GaussianWeights(3, 9), defaultEdges))
+
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			assert.Equal(t, color.RGBA{40, 80, 120, 255}, out.RGBAAt(x, y))
+		}
+	}
+}
+
+func TestGaussianBlur_Arguments(t *testing.T) {
+	valid := []map[string]string{
+		{},
+		{"sigma": "2"},
+		{"radius": "5"},
+		{"sigma": "0.5", "radius": "50"},
+		{"sigma": "16"},
+		{"sigma": "16", "radius": "3"},
+	}
+	for _, args := range valid {
+		_, err := gaussianBlur(args)
+		assert.NoError(t, err, args)
+	}
+
+	invalid := []map[string]string{
+		{"sigma": "0"},
+		{"sigma": "NaN"},
+		{"sigma": "17"},
+		{"sigma": "51"},
+		{"sigma": "wide"},
+		{"radius": "0"},
+		{"radius": "51"},
+		{"radius": "1.5"},
+	}
+	for _, args := range invalid {
+		_, err := gaussianBlur(args)
+		assert.ErrorIs(t, err, ErrInvalidArgument, args)
+	}
+}
+
+// Every sigma gets a default radius of three standard deviations.
+func TestGaussianBlur_MaxSigma(t *testing.T) {
+	assert.LessOrEqual(t, int(math.Ceil(3*MaxBlurSigma)), MaxBlurRadius)
+}
+
+func TestGaussianBlur_DefaultRadius(t *testing.T) {
+	// A sigma of 1 covers three pixels each side, so a single bright pixel
+	// spreads exactly that far.
+	img := image.NewRGBA(image.Rect(0, 0, 15, 1))
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	img.Pix[7*4] = 255
+
+	blur, err := gaussianBlur(map[string]string{"sigma": "1"})
+	assert.NoError(t, err)
+	blurred, err := blur(context.Background(), nil, img)
+	assert.NoError(t, err)
+	out := blurred.(*image.RGBA)
+
+	for x := 0; x < 15; x++ {
+		spread := math.Abs(float64(x-7)) <= 3
+		assert.Equal(t, spread, out.RGBAAt(x, 0).R > 0, x)
+	}
+}
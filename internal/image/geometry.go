@@ -0,0 +1,422 @@
+package image
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Gravities of the crop operation, telling which part of the image is kept
+// when the crop is not positioned explicitly.
+const (
+	GravityCenter    = "center"
+	GravityNorth     = "north"
+	GravitySouth     = "south"
+	GravityEast      = "east"
+	GravityWest      = "west"
+	GravityNorthEast = "northeast"
+	GravityNorthWest = "northwest"
+	GravitySouthEast = "southeast"
+	GravitySouthWest = "southwest"
+)
+
+// Directions of the flip operation.
+const (
+	FlipHorizontal = "horizontal"
+	FlipVertical   = "vertical"
+	FlipBoth       = "both"
+)
+
+var gravities = []string{
+	GravityCenter, GravityNorth, GravitySouth, GravityEast, GravityWest,
+	GravityNorthEast, GravityNorthWest, GravitySouthEast, GravitySouthWest,
+}
+
+func init() {
+	register(Filter{
+		Name:        "crop",
+		Description: "Crops the image to a rectangle, given by its size and position or by an aspect ratio.",
+		Params: []Param{
+			{Name: "width", Type: ParamInteger, Description: "Width of the rectangle in pixels. Defaults to the width of the image.", Minimum: bound(1), Maximum: bound(MaxOutputSide)},
+			{Name: "height", Type: ParamInteger, Description: "Height of the rectangle in pixels. Defaults to the height of the image.", Minimum: bound(1), Maximum: bound(MaxOutputSide)},
+			{Name: "aspect", Type: ParamString, Description: "Aspect ratio of the largest rectangle to keep, such as 16x9 or 1.5, instead of a width and a height."},
+			{Name: "gravity", Type: ParamString, Description: "Part of the image kept when the rectangle is not positioned by x and y.", Enum: gravities, Default: GravityCenter},
+			{Name: "x", Type: ParamInteger, Description: "Left edge of the rectangle. Positions the rectangle by gravity when not given.", Minimum: bound(0)},
+			{Name: "y", Type: ParamInteger, Description: "Top edge of the rectangle. Positions the rectangle by gravity when not given.", Minimum: bound(0)},
+		},
+		compile: compileCrop,
+	})
+	register(Filter{
+		Name:        "flip",
+		Description: "Mirrors the image.",
+		Params: []Param{
+			{Name: "direction", Type: ParamString, Description: "Horizontal mirrors left and right, vertical top and bottom.", Enum: []string{FlipHorizontal, FlipVertical, FlipBoth}, Default: FlipHorizontal},
+		},
+		compile: compileFlip,
+	})
+	register(Filter{
+		Name:        "rotate",
+		Description: "Rotates the image clockwise. Angles other than multiples of 90 enlarge the image to fit the rotated one, filling the corners with the background.",
+		Params: []Param{
+			{Name: "angle", Type: ParamNumber, Description: "Angle in degrees, clockwise.", Required: true, Minimum: bound(-360), Maximum: bound(360)},
+			{Name: "background", Type: ParamString, Description: "Color of the corners, as rrggbb or rrggbbaa hexadecimal. Transparent by default."},
+		},
+		compile: compileRotate,
+	})
+}
+
+// invalidArgument returns an ErrInvalidArgument detailed by format.
+func invalidArgument(format string, a ...any) error {
+	return fmt.Errorf("%w: "+format, append([]any{ErrInvalidArgument}, a...)...)
+}
+
+// crop is a compiled crop operation. Zero sides and a nil x or y are
+// derived from the image.
+type crop struct {
+	width, height int
+	x, y          *int
+	aspect        float64
+	gravity       string
+}
+
+func compileCrop(args map[string]string) (step, error) {
+	cr, err := parseCrop(args)
+	if err != nil {
+		return nil, err
+	}
+	return cr.apply, nil
+}
+
+func parseCrop(args map[string]string) (crop, error) {
+	cr := crop{gravity: GravityCenter}
+	var err error
+
+	for _, side := range []struct {
+		name  string
+		value *int
+	}{{"width", &cr.width}, {"height", &cr.height}} {
+		if raw := args[side.name]; raw != "" {
+			if *side.value, err = strconv.Atoi(raw); err != nil || *side.value < 1 || *side.value > MaxOutputSide {
+				return crop{}, invalidArgument("%s must be an integer between 1 and %d", side.name, MaxOutputSide)
+			}
+		}
+	}
+	for _, edge := range []struct {
+		name  string
+		value **int
+	}{{"x", &cr.x}, {"y", &cr.y}} {
+		if raw := args[edge.name]; raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil || v < 0 {
+				return crop{}, invalidArgument("%s must be a non-negative integer", edge.name)
+			}
+			*edge.value = &v
+		}
+	}
+	if raw := args["gravity"]; raw != "" {
+		cr.gravity = strings.ToLower(raw)
+		if !contains(gravities, cr.gravity) {
+			return crop{}, invalidArgument("gravity must be one of %s", strings.Join(gravities, ", "))
+		}
+	}
+	if raw := args["aspect"]; raw != "" {
+		if cr.aspect, err = parseAspect(raw); err != nil {
+			return crop{}, err
+		}
+	}
+
+	switch {
+	case cr.aspect != 0 && (cr.width != 0 || cr.height != 0):
+		return crop{}, invalidArgument("crop accepts either an aspect ratio or a width and a height")
+	case cr.aspect == 0 && cr.width == 0 && cr.height == 0:
+		return crop{}, invalidArgument("crop requires a width, a height or an aspect ratio")
+	}
+
+	return cr, nil
+}
+
+// parseAspect parses an aspect ratio written as w:h, wxh or a decimal
+// number. Transformation paths separate arguments with colons, so they
+// must use the latter forms.
+func parseAspect(raw string) (float64, error) {
+	invalid := invalidArgument("aspect must be a ratio such as 16x9 or 1.5")
+
+	w, h, found := strings.Cut(strings.ToLower(raw), "x")
+	if !found {
+		w, h, found = strings.Cut(raw, ":")
+	}
+	if !found {
+		aspect, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(aspect > 0) || math.IsInf(aspect, 0) {
+			return 0, invalid
+		}
+		return aspect, nil
+	}
+
+	num, err1 := strconv.ParseFloat(w, 64)
+	den, err2 := strconv.ParseFloat(h, 64)
+	if err1 != nil || err2 != nil || !(num > 0) || !(den > 0) || math.IsInf(num/den, 0) {
+		return 0, invalid
+	}
+	return num / den, nil
+}
+
+// rect returns the rectangle cropped from a w x h image, clipped to it.
+func (cr crop) rect(w, h int) image.Rectangle {
+	cw, ch := cr.width, cr.height
+	switch {
+	case cr.aspect != 0 && float64(w)/float64(h) > cr.aspect:
+		ch = h
+		cw = max(int(math.Round(float64(h)*cr.aspect)), 1)
+	case cr.aspect != 0:
+		cw = w
+		ch = max(int(math.Round(float64(w)/cr.aspect)), 1)
+	}
+	if cw == 0 {
+		cw = w
+	}
+	if ch == 0 {
+		ch = h
+	}
+
+	// Gravity aligns the rectangle on the sides it names, and centers it
+	// on the others.
+	x, y := (w-cw)/2, (h-ch)/2
+	if strings.Contains(cr.gravity, GravityWest) {
+		x = 0
+	} else if strings.Contains(cr.gravity, GravityEast) {
+		x = w - cw
+	}
+	if strings.HasPrefix(cr.gravity, GravityNorth) {
+		y = 0
+	} else if strings.HasPrefix(cr.gravity, GravitySouth) {
+		y = h - ch
+	}
+	if cr.x != nil {
+		x = *cr.x
+	}
+	if cr.y != nil {
+		y = *cr.y
+	}
+
+	return image.Rect(x, y, x+cw, y+ch).Intersect(image.Rect(0, 0, w, h))
+}
+
+func (cr crop) apply(ctx context.Context, pool *Pool, img image.Image) (image.Image, error) {
+	src := toRGBA(img)
+	rect := cr.rect(src.Rect.Dx(), src.Rect.Dy())
+	if rect.Empty() {
+		return nil, invalidArgument("crop rectangle lies outside the %dx%d image", src.Rect.Dx(), src.Rect.Dy())
+	}
+
+	return cropImage(ctx, pool, src, rect)
+}
+
+// cropImage returns a copy of the rectangle rect of src, which must lie
+// within it.
+func cropImage(ctx context.Context, pool *Pool, src *image.RGBA, rect image.Rectangle) (*image.RGBA, error) {
+	dst := image.NewRGBA(image.Rectangle{Max: rect.Size()})
+	err := pool.rows(ctx, dst.Rect.Dy(), func(y0, y1 int) {
+		for y := y0; y < y1 && ctx.Err() == nil; y++ {
+			o := (y+rect.Min.Y)*src.Stride + rect.Min.X*4
+			copy(dst.Pix[y*dst.Stride:], src.Pix[o:o+dst.Rect.Dx()*4])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+func compileFlip(args map[string]string) (step, error) {
+	orientation := 2
+	switch direction := strings.ToLower(args["direction"]); direction {
+	case "", FlipHorizontal:
+	case FlipVertical:
+		orientation = 4
+	case FlipBoth:
+		orientation = 3
+	default:
+		return nil, invalidArgument("direction must be one of %s, %s or %s", FlipHorizontal, FlipVertical, FlipBoth)
+	}
+
+	return func(ctx context.Context, pool *Pool, img image.Image) (image.Image, error) {
+		return orient(ctx, pool, toRGBA(img), orientation)
+	}, nil
+}
+
+// rotations are the EXIF orientations rotating images clockwise by
+// multiples of 90 degrees.
+var rotations = [4]int{1, 6, 3, 8}
+
+func compileRotate(args map[string]string) (step, error) {
+	angle, err := strconv.ParseFloat(args["angle"], 64)
+	if err != nil || !(angle >= -360 && angle <= 360) {
+		return nil, invalidArgument("angle must be a number of degrees between -360 and 360")
+	}
+	background, err := parseColor(args["background"])
+	if err != nil {
+		return nil, err
+	}
+
+	angle = math.Mod(angle+360, 360)
+	if quarter := angle / 90; quarter == math.Trunc(quarter) {
+		orientation := rotations[int(quarter)]
+		return func(ctx context.Context, pool *Pool, img image.Image) (image.Image, error) {
+			return orient(ctx, pool, toRGBA(img), orientation)
+		}, nil
+	}
+
+	return func(ctx context.Context, pool *Pool, img image.Image) (image.Image, error) {
+		return rotate(ctx, pool, toRGBA(img), angle, background)
+	}, nil
+}
+
+// parseColor parses a color written as rrggbb or rrggbbaa hexadecimal,
+// optionally preceded by #, into its premultiplied channels. The empty
+// string is transparent.
+func parseColor(raw string) ([4]float32, error) {
+	var color [4]float32
+	if raw == "" {
+		return color, nil
+	}
+
+	channels, err := hex.DecodeString(strings.TrimPrefix(raw, "#"))
+	if err != nil || (len(channels) != 3 && len(channels) != 4) {
+		return color, invalidArgument("background must be a color such as ff0000 or ff000080")
+	}
+	alpha := float32(255)
+	if len(channels) == 4 {
+		alpha = float32(channels[3])
+	}
+	for i := 0; i < 3; i++ {
+		color[i] = float32(channels[i]) * alpha / 255
+	}
+	color[3] = alpha
+	return color, nil
+}
+
+// rotate rotates src clockwise by angle degrees onto a canvas enlarged to
+// fit it. Every output pixel is sampled bilinearly from the source at its
+// inverse rotation, the background standing in for the samples outside the
+// source so that the edges of the rotated image are antialiased.
+func rotate(ctx context.Context, pool *Pool, src *image.RGBA, angle float64, background [4]float32) (*image.RGBA, error) {
+	w, h := float64(src.Rect.Dx()), float64(src.Rect.Dy())
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+
+	// The tolerance keeps rounding errors from adding a row or a column.
+	size := func(v float64) int {
+		return max(int(math.Ceil(v-1e-9)), 1)
+	}
+	canvas := image.Pt(size(w*math.Abs(cos)+h*math.Abs(sin)), size(w*math.Abs(sin)+h*math.Abs(cos)))
+	if err := checkOutput(canvas); err != nil {
+		return nil, err
+	}
+	if err := reserve(ctx, canvas); err != nil {
+		return nil, err
+	}
+
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	sample := func(x, y int) [4]float32 {
+		if x < 0 || y < 0 || x >= sw || y >= sh {
+			return background
+		}
+		o := y*src.Stride + x*4
+		return [4]float32{float32(src.Pix[o]), float32(src.Pix[o+1]), float32(src.Pix[o+2]), float32(src.Pix[o+3])}
+	}
+
+	dst := image.NewRGBA(image.Rectangle{Max: canvas})
+	cx, cy := float64(canvas.X)/2, float64(canvas.Y)/2
+	err := pool.rows(ctx, canvas.Y, func(y0, y1 int) {
+		for y := y0; y < y1 && ctx.Err() == nil; y++ {
+			for x := 0; x < canvas.X; x++ {
+				dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+				u := dx*cos + dy*sin + w/2 - 0.5
+				v := -dx*sin + dy*cos + h/2 - 0.5
+
+				x0, y0 := math.Floor(u), math.Floor(v)
+				fx, fy := float32(u-x0), float32(v-y0)
+				sx, sy := int(x0), int(y0)
+				p00, p10 := sample(sx, sy), sample(sx+1, sy)
+				p01, p11 := sample(sx, sy+1), sample(sx+1, sy+1)
+
+				var c [4]float32
+				for i := range c {
+					top := p00[i] + (p10[i]-p00[i])*fx
+					bottom := p01[i] + (p11[i]-p01[i])*fx
+					c[i] = top + (bottom-top)*fy
+				}
+
+				o := y*dst.Stride + x*4
+				alpha := toChannel(c[3], 255)
+				dst.Pix[o] = toChannel(c[0], alpha)
+				dst.Pix[o+1] = toChannel(c[1], alpha)
+				dst.Pix[o+2] = toChannel(c[2], alpha)
+				dst.Pix[o+3] = alpha
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// orientations tells, for every EXIF orientation, how the pixel (x, y) of
+// the oriented image is found in the source: the coordinates are swapped
+// when transpose is set, then mirrored along the flipped axes.
+var orientations = [9]struct{ transpose, flipX, flipY bool }{
+	1: {},
+	2: {flipX: true},
+	3: {flipX: true, flipY: true},
+	4: {flipY: true},
+	5: {transpose: true},
+	6: {transpose: true, flipY: true},
+	7: {transpose: true, flipX: true, flipY: true},
+	8: {transpose: true, flipX: true},
+}
+
+// orient returns src transformed as EXIF orientation tells for it to be
+// displayed upright: 2 to 4 mirror or turn it upside down, 5 to 8 also
+// swap its sides.
+func orient(ctx context.Context, pool *Pool, src *image.RGBA, orientation int) (*image.RGBA, error) {
+	t := orientations[orientation]
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	size := image.Pt(w, h)
+	if t.transpose {
+		size = image.Pt(h, w)
+	}
+
+	offset := func(x, y int) int {
+		if t.transpose {
+			x, y = y, x
+		}
+		if t.flipX {
+			x = w - 1 - x
+		}
+		if t.flipY {
+			y = h - 1 - y
+		}
+		return y*src.Stride + x*4
+	}
+
+	dst := image.NewRGBA(image.Rectangle{Max: size})
+	err := pool.rows(ctx, size.Y, func(y0, y1 int) {
+		for y := y0; y < y1 && ctx.Err() == nil; y++ {
+			o, step := offset(0, y), offset(1, y)-offset(0, y)
+			row := dst.Pix[y*dst.Stride : y*dst.Stride+size.X*4]
+			for x := 0; x < len(row); x += 4 {
+				copy(row[x:x+4], src.Pix[o:o+4])
+				o += step
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
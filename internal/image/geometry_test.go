@@ -0,0 +1,207 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/drew138/graphics-api/internal/admission"
+)
+
+func compileStep(t *testing.T, name string, args map[string]string) step {
+	filter, ok := LookupFilter(name)
+	assert.True(t, ok, name)
+	s, err := filter.compile(args)
+	assert.NoError(t, err, args)
+	return s
+}
+
+func TestCrop_Rect(t *testing.T) {
+	cases := []struct {
+		args map[string]string
+		rect image.Rectangle
+	}{
+		{map[string]string{"width": "40", "height": "20"}, image.Rect(30, 15, 70, 35)},
+		{map[string]string{"width": "40", "height": "20", "gravity": "northwest"}, image.Rect(0, 0, 40, 20)},
+		{map[string]string{"width": "40", "height": "20", "gravity": "southeast"}, image.Rect(60, 30, 100, 50)},
+		{map[string]string{"width": "40", "height": "20", "gravity": "east"}, image.Rect(60, 15, 100, 35)},
+		{map[string]string{"width": "40", "height": "20", "x": "90", "y": "0"}, image.Rect(90, 0, 100, 20)},
+		{map[string]string{"width": "10"}, image.Rect(45, 0, 55, 50)},
+		{map[string]string{"aspect": "1:1"}, image.Rect(25, 0, 75, 50)},
+		{map[string]string{"aspect": "4x1", "gravity": "south"}, image.Rect(0, 25, 100, 50)},
+		{map[string]string{"aspect": "0.5", "x": "0"}, image.Rect(0, 0, 25, 50)},
+	}
+
+	for _, tc := range cases {
+		cr, err := parseCrop(tc.args)
+		assert.NoError(t, err, tc.args)
+		assert.Equal(t, tc.rect, cr.rect(100, 50), tc.args)
+	}
+}
+
+func TestCrop_Apply(t *testing.T) {
+	src := gradient(100, 50)
+	out, err := compileStep(t, "crop", map[string]string{"width": "40", "height": "20", "x": "5", "y": "7"})(context.Background(), NewPool(2), src)
+
+	assert.NoError(t, err)
+	assert.Equal(t, image.Pt(40, 20), out.Bounds().Size())
+	for _, p := range []image.Point{{0, 0}, {39, 0}, {17, 11}, {39, 19}} {
+		assert.Equal(t, src.At(p.X+5, p.Y+7), out.At(p.X, p.Y), p)
+	}
+}
+
+func TestCrop_Errors(t *testing.T) {
+	cases := []map[string]string{
+		{},
+		{"x": "10"},
+		{"width": "0"},
+		{"width": "10", "x": "-1"},
+		{"width": "10", "gravity": "up"},
+		{"aspect": "wide"},
+		{"aspect": "16:0"},
+		{"aspect": "16x9", "width": "10"},
+	}
+	for _, args := range cases {
+		_, err := compileCrop(args)
+		assert.ErrorIs(t, err, ErrInvalidArgument, args)
+	}
+
+	// Rectangles outside of the image leave nothing to keep.
+	_, err := compileStep(t, "crop", map[string]string{"width": "10", "x": "200"})(context.Background(), nil, gradient(100, 50))
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestOrient(t *testing.T) {
+	src := gradient(5, 3)
+	ctx := context.Background()
+
+	// Corners of the source found at the top left of the oriented image.
+	corners := map[int]image.Point{1: {0, 0}, 2: {4, 0}, 3: {4, 2}, 4: {0, 2}, 5: {0, 0}, 6: {0, 2}, 7: {4, 2}, 8: {4, 0}}
+	for orientation, corner := range corners {
+		out, err := orient(ctx, NewPool(2), src, orientation)
+		assert.NoError(t, err)
+
+		size := image.Pt(5, 3)
+		if orientation >= 5 {
+			size = image.Pt(3, 5)
+		}
+		assert.Equal(t, size, out.Rect.Size(), orientation)
+		assert.Equal(t, src.At(corner.X, corner.Y), out.At(0, 0), orientation)
+	}
+
+	// 6 and 8 are inverse rotations, the others their own inverse.
+	inverses := map[int]int{2: 2, 3: 3, 4: 4, 5: 5, 6: 8, 7: 7, 8: 6}
+	for orientation, inverse := range inverses {
+		out, _ := orient(ctx, nil, src, orientation)
+		back, _ := orient(ctx, nil, out, inverse)
+		assert.Equal(t, src.Pix, back.Pix, orientation)
+	}
+}
+
+func TestFlip(t *testing.T) {
+	src := gradient(5, 3)
+	cases := map[string]image.Point{"": {4, 0}, FlipHorizontal: {4, 0}, FlipVertical: {0, 2}, FlipBoth: {4, 2}}
+	for direction, corner := range cases {
+		out, err := compileStep(t, "flip", map[string]string{"direction": direction})(context.Background(), nil, src)
+
+		assert.NoError(t, err)
+		assert.Equal(t, src.Bounds(), out.Bounds(), direction)
+		assert.Equal(t, src.At(corner.X, corner.Y), out.At(0, 0), direction)
+	}
+
+	_, err := compileFlip(map[string]string{"direction": "diagonal"})
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestRotate_RightAngles(t *testing.T) {
+	src := gradient(5, 3)
+	ctx := context.Background()
+
+	for angle, orientation := range map[string]int{"0": 1, "90": 6, "180": 3, "270": 8, "-90": 8, "360": 1} {
+		want, _ := orient(ctx, nil, src, orientation)
+		out, err := compileStep(t, "rotate", map[string]string{"angle": angle})(ctx, nil, src)
+
+		assert.NoError(t, err)
+		assert.Equal(t, want, out, angle)
+	}
+}
+
+func TestRotate_Arbitrary(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for i := 0; i < len(src.Pix); i += 4 {
+		src.Pix[i], src.Pix[i+3] = 255, 255
+	}
+
+	out, err := compileStep(t, "rotate", map[string]string{"angle": "45"})(context.Background(), NewPool(2), src)
+	assert.NoError(t, err)
+	assert.Equal(t, image.Pt(15, 15), out.Bounds().Size())
+	assert.Equal(t, color.RGBA{}, out.At(0, 0))
+	assert.Equal(t, color.RGBA{255, 0, 0, 255}, out.At(7, 7))
+
+	out, err = compileStep(t, "rotate", map[string]string{"angle": "45", "background": "#0000ff80"})(context.Background(), nil, src)
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA{0, 0, 128, 128}, out.At(0, 0))
+}
+
+func TestRotate_Errors(t *testing.T) {
+	cases := []map[string]string{
+		{},
+		{"angle": "right"},
+		{"angle": "400"},
+		{"angle": "45", "background": "red"},
+		{"angle": "45", "background": "fff"},
+	}
+	for _, args := range cases {
+		_, err := compileRotate(args)
+		assert.ErrorIs(t, err, ErrInvalidArgument, args)
+	}
+
+	// Enlarging beyond the maximum size is rejected before allocating.
+	_, err := compileStep(t, "rotate", map[string]string{"angle": "45"})(context.Background(), nil, image.NewRGBA(image.Rect(0, 0, MaxOutputSide, 1)))
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestRotate_Budget(t *testing.T) {
+	budget := admission.NewBudget(150, 10*time.Millisecond)
+	service := NewService(WithBudget(budget))
+	apply := func(angle string) error {
+		_, err := service.ApplyPipeline(context.Background(), gradient(10, 10), []Operation{{Name: "rotate", Args: map[string]string{"angle": angle}}}, EncodeOptions{Format: FormatPNG})
+		return err
+	}
+
+	// Right angles keep the number of pixels, while 45 degrees doubles it.
+	assert.NoError(t, apply("90"))
+	assert.ErrorIs(t, apply("45"), ErrInvalidArgument)
+	assert.NoError(t, apply("10"))
+
+	release, _ := budget.Acquire(context.Background(), 120)
+	defer release()
+	assert.ErrorIs(t, apply("10"), admission.ErrBudgetExhausted)
+	assert.NoError(t, apply("180"))
+}
+
+func TestRotate_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := compileStep(t, "rotate", map[string]string{"angle": "30"})(ctx, NewPool(2), gradient(128, 128))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGeometry_Pipeline(t *testing.T) {
+	transformation, err := ParsePath("rotate:90/crop:::1x1/flip:vertical/cat.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "rotate:90/crop:::1x1/flip:vertical/cat.png", transformation.String())
+
+	out, err := NewService().ApplyPipeline(context.Background(), gradient(64, 48), transformation.Operations, EncodeOptions{Format: FormatPNG})
+	assert.NoError(t, err)
+
+	img, _, err := Decode(bytes.NewReader(out), Limits{})
+	assert.NoError(t, err)
+	assert.Equal(t, image.Pt(48, 48), img.Bounds().Size())
+}
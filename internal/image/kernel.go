@@ -0,0 +1,85 @@
+package image
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/drew138/go-graphics/filters/kernels"
+)
+
+const (
+	// MaxKernelSize is the largest side accepted for a custom kernel.
+	MaxKernelSize = 31
+	// MaxKernelWeight bounds the absolute value of every kernel entry.
+	MaxKernelWeight = 1000
+)
+
+var (
+	ErrKernelMalformed  = errors.New("kernel must be a JSON matrix of numbers")
+	ErrKernelShape      = fmt.Errorf("kernel must be a square matrix with an odd side between 1 and %d", MaxKernelSize)
+	ErrKernelRange      = fmt.Errorf("kernel weights must be finite and within [-%d, %d]", MaxKernelWeight, MaxKernelWeight)
+	ErrKernelDegenerate = errors.New("kernel must contain at least one non-zero weight")
+)
+
+// ParseKernel decodes a convolution matrix in the form
+// [[v1,v2,v3],[v4,v5,v6],[v7,v8,v9]] and validates it.
+func ParseKernel(raw string) (kernels.Kernel, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, ErrKernelMalformed
+	}
+
+	var matrix [][]float64
+	if err := json.Unmarshal([]byte(raw), &matrix); err != nil {
+		return nil, ErrKernelMalformed
+	}
+
+	kernel := make(kernels.Kernel, len(matrix))
+	for i, row := range matrix {
+		kernel[i] = make([]float32, len(row))
+		for j, v := range row {
+			if math.IsNaN(v) || math.Abs(v) > MaxKernelWeight {
+				return nil, ErrKernelRange
+			}
+			kernel[i][j] = float32(v)
+		}
+	}
+
+	if err := ValidateKernel(kernel); err != nil {
+		return nil, err
+	}
+
+	return kernel, nil
+}
+
+// ValidateKernel checks that a kernel can be applied to an image.
+func ValidateKernel(kernel kernels.Kernel) error {
+	n := len(kernel)
+	if n == 0 || n > MaxKernelSize || n%2 == 0 {
+		return ErrKernelShape
+	}
+
+	nonZero := false
+	for _, row := range kernel {
+		if len(row) != n {
+			return ErrKernelShape
+		}
+		for _, v := range row {
+			if math.IsNaN(float64(v)) || math.Abs(float64(v)) > MaxKernelWeight {
+				return ErrKernelRange
+			}
+			if v != 0 {
+				nonZero = true
+			}
+		}
+	}
+
+	if !nonZero {
+		return ErrKernelDegenerate
+	}
+
+	return nil
+}
@@ -0,0 +1,34 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/drew138/go-graphics/filters/kernels"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKernel(t *testing.T) {
+	kernel, err := ParseKernel("[[0, -1, 0], [-1, 5, -1], [0, -1, 0]]")
+
+	assert.NoError(t, err)
+	assert.Equal(t, kernels.Sharpen, [][]float32(kernel))
+}
+
+func TestParseKernel_Errors(t *testing.T) {
+	cases := map[string]error{
+		"":                            ErrKernelMalformed,
+		"not json":                    ErrKernelMalformed,
+		`[["a"]]`:                     ErrKernelMalformed,
+		"[]":                          ErrKernelShape,
+		"[[1,2],[3,4]]":               ErrKernelShape,
+		"[[1,2,3],[4,5,6]]":           ErrKernelShape,
+		"[[1,2,3],[4,5],[7,8,9]]":     ErrKernelShape,
+		"[[1,2,3],[4,5,6],[7,8,1e9]]": ErrKernelRange,
+		"[[0,0,0],[0,0,0],[0,0,0]]":   ErrKernelDegenerate,
+	}
+
+	for raw, expected := range cases {
+		_, err := ParseKernel(raw)
+		assert.ErrorIs(t, err, expected, raw)
+	}
+}
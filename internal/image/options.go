@@ -0,0 +1,124 @@
+package image
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/bmp"
+)
+
+// PNG compression levels accepted in EncodeOptions.
+const (
+	CompressionDefault = "default"
+	CompressionNone    = "none"
+	CompressionFast    = "fast"
+	CompressionBest    = "best"
+)
+
+// GIF dithering modes accepted in EncodeOptions.
+const (
+	DitherFloydSteinberg = "floydsteinberg"
+	DitherNone           = "none"
+)
+
+var (
+	ErrInvalidQuality     = errors.New("quality must be between 1 and 100")
+	ErrInvalidCompression = fmt.Errorf("compression must be one of %s, %s, %s or %s", CompressionDefault, CompressionNone, CompressionFast, CompressionBest)
+	ErrInvalidColors      = errors.New("colors must be between 2 and 256")
+	ErrInvalidDither      = fmt.Errorf("dither must be either %s or %s", DitherFloydSteinberg, DitherNone)
+)
+
+var compressionLevels = map[string]png.CompressionLevel{
+	CompressionDefault: png.DefaultCompression,
+	CompressionNone:    png.NoCompression,
+	CompressionFast:    png.BestSpeed,
+	CompressionBest:    png.BestCompression,
+}
+
+// EncodeOptions controls how a processed image is encoded. Zero values
+// select each encoder's defaults, and options that do not apply to Format
+// are ignored.
+type EncodeOptions struct {
+	Format string
+	// Quality is the JPEG quality, from 1 to 100.
+	Quality int
+	// Compression is the PNG compression level.
+	Compression string
+	// Colors is the GIF palette size, from 2 to 256.
+	Colors int
+	// Dither is the GIF dithering mode.
+	Dither string
+}
+
+// Validate checks that every option is within its accepted range.
+func (o EncodeOptions) Validate() error {
+	if o.Quality != 0 && (o.Quality < 1 || o.Quality > 100) {
+		return ErrInvalidQuality
+	}
+	if _, ok := compressionLevels[o.Compression]; o.Compression != "" && !ok {
+		return ErrInvalidCompression
+	}
+	if o.Colors != 0 && (o.Colors < 2 || o.Colors > 256) {
+		return ErrInvalidColors
+	}
+	if o.Dither != "" && o.Dither != DitherFloydSteinberg && o.Dither != DitherNone {
+		return ErrInvalidDither
+	}
+	return nil
+}
+
+func init() {
+	RegisterEncoder(FormatJPEG, "image/jpeg", encodeJPEG)
+	RegisterEncoder(FormatPNG, "image/png", encodePNG)
+	RegisterEncoder(FormatGIF, "image/gif", encodeGIF)
+	RegisterEncoder(FormatBMP, "image/bmp", encodeBMP)
+}
+
+func encodeJPEG(w io.Writer, img image.Image, opts EncodeOptions) error {
+	quality := jpeg.DefaultQuality
+	if opts.Quality != 0 {
+		quality = opts.Quality
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
+
+func encodePNG(w io.Writer, img image.Image, opts EncodeOptions) error {
+	encoder := png.Encoder{CompressionLevel: compressionLevels[opts.Compression]}
+	return encoder.Encode(w, img)
+}
+
+func encodeGIF(w io.Writer, img image.Image, opts EncodeOptions) error {
+	colors := 256
+	if opts.Colors != 0 {
+		colors = opts.Colors
+	}
+
+	var drawer draw.Drawer = draw.FloydSteinberg
+	if opts.Dither == DitherNone {
+		drawer = draw.Src
+	}
+
+	// Building the palette is the slow part of the encoding, so it stops
+	// early when the image is encoded for a request that is done.
+	ctx := context.Background()
+	if cw, ok := w.(contextWriter); ok {
+		ctx = cw.ctx
+	}
+
+	return gif.Encode(w, img, &gif.Options{
+		NumColors: colors,
+		Quantizer: medianCut{ctx},
+		Drawer:    drawer,
+	})
+}
+
+func encodeBMP(w io.Writer, img image.Image, _ EncodeOptions) error {
+	return bmp.Encode(w, img)
+}
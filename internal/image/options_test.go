@@ -0,0 +1,56 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gradient(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{uint8(x * 255 / width), uint8(y * 255 / height), uint8((x + y) % 256), 255})
+		}
+	}
+	return img
+}
+
+func TestEncodeOptions_Validate(t *testing.T) {
+	cases := map[error]EncodeOptions{
+		nil:                   {Format: FormatJPEG, Quality: 90, Compression: CompressionBest, Colors: 16, Dither: DitherNone},
+		ErrInvalidQuality:     {Quality: 101},
+		ErrInvalidCompression: {Compression: "max"},
+		ErrInvalidColors:      {Colors: 1},
+		ErrInvalidDither:      {Dither: "ordered"},
+	}
+
+	for expected, opts := range cases {
+		assert.Equal(t, expected, opts.Validate())
+	}
+}
+
+func TestEncodeOptions_JPEGQuality(t *testing.T) {
+	img := gradient(64, 64)
+
+	low, err := NewService().ApplyPipeline(context.Background(), img, []Operation{{Name: "sharpen"}}, EncodeOptions{Format: FormatJPEG, Quality: 10})
+	assert.NoError(t, err)
+	high, err := NewService().ApplyPipeline(context.Background(), img, []Operation{{Name: "sharpen"}}, EncodeOptions{Format: FormatJPEG, Quality: 100})
+	assert.NoError(t, err)
+
+	assert.Less(t, len(low), len(high))
+}
+
+func TestEncodeOptions_GIFColors(t *testing.T) {
+	out, err := NewService().ApplyPipeline(context.Background(), gradient(32, 32), []Operation{{Name: "boxblur"}}, EncodeOptions{Format: FormatGIF, Colors: 8, Dither: DitherNone})
+	assert.NoError(t, err)
+
+	decoded, err := gif.Decode(bytes.NewReader(out))
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, len(decoded.(*image.Paletted).Palette), 8)
+}
@@ -0,0 +1,192 @@
+package image
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrPathMalformed = errors.New("malformed transformation path")
+
+// operationAliases are the short operation names accepted in paths.
+var operationAliases = map[string]string{
+	"blur": "gaussianblur",
+}
+
+// pathOptions lists the encoder options that can appear in a path, in the
+// order they are written in canonical paths.
+var pathOptions = []string{"format", "quality", "compression", "colors", "dither"}
+
+// Transformation is the parsed form of a path such as
+// blur:2/sharpen/format:png/<source-id>.
+type Transformation struct {
+	Operations []Operation
+	// Options holds the encoder options found in the path. An empty Format
+	// means the image is served in the format of its source.
+	Options EncodeOptions
+	Source  string
+}
+
+// ParsePath parses a transformation path. Every segment but the last is
+// either an operation, written as name[:arg...] with its arguments in
+// declaration order, or an encoder option written as option:value. The
+// last segment is the identifier of the source image.
+func ParsePath(path string) (Transformation, error) {
+	var t Transformation
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	t.Source = segments[len(segments)-1]
+	if t.Source == "" {
+		return t, fmt.Errorf("%w: missing source", ErrPathMalformed)
+	}
+
+	seen := map[string]bool{}
+	for _, segment := range segments[:len(segments)-1] {
+		if segment == "" {
+			return t, fmt.Errorf("%w: empty segment", ErrPathMalformed)
+		}
+
+		parts := strings.Split(segment, ":")
+		name, args := strings.ToLower(parts[0]), parts[1:]
+		if alias, ok := operationAliases[name]; ok {
+			name = alias
+		}
+
+		if contains(pathOptions, name) {
+			if seen[name] {
+				return t, fmt.Errorf("%w: %s given more than once", ErrPathMalformed, name)
+			}
+			seen[name] = true
+			if len(args) != 1 || args[0] == "" {
+				return t, fmt.Errorf("%w: %s requires exactly one value", ErrPathMalformed, name)
+			}
+			if err := t.setOption(name, args[0]); err != nil {
+				return t, err
+			}
+			continue
+		}
+
+		filter, ok := LookupFilter(name)
+		if !ok {
+			return t, fmt.Errorf("%w: %q", ErrUnknownOperation, name)
+		}
+		if len(args) > len(filter.Params) {
+			return t, fmt.Errorf("%w: %s accepts at most %d arguments", ErrInvalidArgument, name, len(filter.Params))
+		}
+
+		op := Operation{Name: name, Args: map[string]string{}}
+		for i, arg := range args {
+			p := filter.Params[i]
+			op.Args[p.Name] = canonicalArg(p, arg)
+		}
+		t.Operations = append(t.Operations, op)
+	}
+
+	if _, err := compile(t.Operations); err != nil {
+		return t, err
+	}
+	if err := t.Options.Validate(); err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+func (t *Transformation) setOption(name, value string) error {
+	var err error
+	switch name {
+	case "format":
+		var ok bool
+		if t.Options.Format, ok = ParseFormat(value); !ok {
+			return ErrUnsupportedFormat
+		}
+	case "quality":
+		if t.Options.Quality, err = strconv.Atoi(value); err != nil || t.Options.Quality == 0 {
+			return ErrInvalidQuality
+		}
+	case "compression":
+		t.Options.Compression = strings.ToLower(value)
+	case "colors":
+		if t.Options.Colors, err = strconv.Atoi(value); err != nil || t.Options.Colors == 0 {
+			return ErrInvalidColors
+		}
+	case "dither":
+		t.Options.Dither = strings.ToLower(value)
+	}
+	return nil
+}
+
+// String returns the canonical form of the path: operations keep their
+// order, use their full names and normalized arguments, without those
+// left to their default, and are followed by the encoder options in a
+// fixed order. Equivalent paths share the same canonical form, which makes
+// it suitable as a cache key.
+func (t Transformation) String() string {
+	var segments []string
+
+	for _, op := range t.Operations {
+		filter, _ := LookupFilter(op.Name)
+		values := make([]string, len(filter.Params))
+		n := 0
+		for i, p := range filter.Params {
+			if value := op.Args[p.Name]; !p.isDefault(value) {
+				values[i], n = value, i+1
+			}
+		}
+		segments = append(segments, strings.Join(append([]string{op.Name}, values[:n]...), ":"))
+	}
+
+	values := map[string]string{
+		"format":      t.Options.Format,
+		"compression": t.Options.Compression,
+		"dither":      t.Options.Dither,
+	}
+	if t.Options.Quality != 0 {
+		values["quality"] = strconv.Itoa(t.Options.Quality)
+	}
+	if t.Options.Colors != 0 {
+		values["colors"] = strconv.Itoa(t.Options.Colors)
+	}
+	for _, option := range pathOptions {
+		if values[option] != "" {
+			segments = append(segments, option+":"+values[option])
+		}
+	}
+
+	return strings.Join(append(segments, t.Source), "/")
+}
+
+// canonicalArg normalizes the spelling of an argument of type p.Type so
+// that, for instance, 2, 2.0 and 2e0 are written the same way, as are
+// kernels differing only by their spacing and enumerated values differing
+// only by their case. Other strings, such as colors, are kept as given.
+func canonicalArg(p Param, arg string) string {
+	switch {
+	case p.Type == ParamNumber || p.Type == ParamInteger:
+		if f, err := strconv.ParseFloat(arg, 64); err == nil {
+			return strconv.FormatFloat(f, 'g', -1, 64)
+		}
+	case p.Type == ParamBoolean:
+		if b, err := strconv.ParseBool(arg); err == nil {
+			return strconv.FormatBool(b)
+		}
+	case p.Type == ParamKernel:
+		var value any
+		if err := json.Unmarshal([]byte(arg), &value); err == nil {
+			if canonical, err := json.Marshal(value); err == nil {
+				return string(canonical)
+			}
+		}
+	case p.Enum != nil:
+		return strings.ToLower(arg)
+	}
+	return arg
+}
+
+// isDefault reports whether the canonical value leaves the argument to its
+// default, either by being empty or by being equal to it.
+func (p Param) isDefault(value string) bool {
+	return value == "" || (p.Default != nil && value == canonicalArg(p, fmt.Sprint(p.Default)))
+}
@@ -0,0 +1,107 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePath(t *testing.T) {
+	transformation, err := ParsePath("/boxblur/Sharpen/quality:90/format:JPG/cat.png")
+
+	assert.NoError(t, err)
+	assert.Equal(t, Transformation{
+		Operations: []Operation{
+			{Name: "boxblur", Args: map[string]string{}},
+			{Name: "sharpen", Args: map[string]string{}},
+		},
+		Options: EncodeOptions{Format: FormatJPEG, Quality: 90},
+		Source:  "cat.png",
+	}, transformation)
+	assert.Equal(t, "boxblur/sharpen/format:jpeg/quality:90/cat.png", transformation.String())
+}
+
+func TestParsePath_Canonical(t *testing.T) {
+	equivalent := []string{
+		"blur/custom:[[0, -1, 0], [-1, 5, -1], [0, -1, 0]]/format:png/cat.png",
+		"/gaussianblur/CUSTOM:[[0,-1.0,0],[-1,5e0,-1],[0,-1,0]]/format:PNG/cat.png/",
+	}
+
+	for _, path := range equivalent {
+		transformation, err := ParsePath(path)
+
+		assert.NoError(t, err, path)
+		assert.Equal(t, "gaussianblur/custom:[[0,-1,0],[-1,5,-1],[0,-1,0]]/format:png/cat.png", transformation.String(), path)
+	}
+}
+
+func TestParsePath_Defaults(t *testing.T) {
+	equivalent := [][2]string{
+		{"sharpen:MIRROR/cat.png", "sharpen:mirror/cat.png"},
+		{"resize:100::INSIDE/cat.png", "resize:100::inside/cat.png"},
+		{"sharpen:/cat.png", "sharpen/cat.png"},
+		{"sharpen:clamp/cat.png", "sharpen/cat.png"},
+		{"gaussianblur:::clamp/cat.png", "gaussianblur/cat.png"},
+		{"resize:100::cover:Lanczos3::1/cat.png", "resize:100/cat.png"},
+		{"crop:10:10::CENTER:0/cat.png", "crop:10:10:::0/cat.png"},
+	}
+
+	for _, paths := range equivalent {
+		for _, path := range paths {
+			transformation, err := ParsePath(path)
+
+			assert.NoError(t, err, path)
+			assert.Equal(t, paths[1], transformation.String(), path)
+		}
+	}
+}
+
+// Colors are not numbers, even when they are only made of digits.
+func TestParsePath_Colors(t *testing.T) {
+	for _, path := range []string{"rotate:45:000000/cat.png", "sharpen:constant:000000/cat.png", "rotate:45:123e45/cat.png"} {
+		transformation, err := ParsePath(path)
+
+		assert.NoError(t, err, path)
+		assert.Equal(t, path, transformation.String())
+	}
+}
+
+func TestParsePath_SourceOnly(t *testing.T) {
+	transformation, err := ParsePath("cat.png")
+
+	assert.NoError(t, err)
+	assert.Empty(t, transformation.Operations)
+	assert.Equal(t, "cat.png", transformation.String())
+}
+
+func TestParsePath_Errors(t *testing.T) {
+	cases := map[string]error{
+		"":                              ErrPathMalformed,
+		"sharpen//cat.png":              ErrPathMalformed,
+		"emboss/cat.png":                ErrUnknownOperation,
+		"sharpen:2/cat.png":             ErrInvalidArgument,
+		"custom:[[1,2]]/cat.png":        ErrKernelShape,
+		"format:webp/cat.png":           ErrUnsupportedFormat,
+		"format/cat.png":                ErrPathMalformed,
+		"format:png/format:gif/cat.png": ErrPathMalformed,
+		"quality:0/cat.png":             ErrInvalidQuality,
+		"quality:high/cat.png":          ErrInvalidQuality,
+		"compression:max/cat.png":       ErrInvalidCompression,
+		"colors:300/cat.png":            ErrInvalidColors,
+		"dither:ordered/cat.png":        ErrInvalidDither,
+		"colors:16:dither:none/cat.png": ErrPathMalformed,
+	}
+
+	for path, expected := range cases {
+		_, err := ParsePath(path)
+		assert.ErrorIs(t, err, expected, path)
+	}
+}
+
+func TestParsePath_PositionalArguments(t *testing.T) {
+	transformation, err := ParsePath("blur:2.0:7/cat.png")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"sigma": "2", "radius": "7"}, transformation.Operations[0].Args)
+	assert.Equal(t, "gaussianblur:2:7/cat.png", transformation.String())
+}
@@ -0,0 +1,154 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+)
+
+// MaxOperations bounds the number of steps in a single pipeline.
+const MaxOperations = 16
+
+var (
+	ErrPipelineMalformed = errors.New(`operations must be a JSON list of objects such as {"op": "sharpen"}`)
+	ErrPipelineLength    = fmt.Errorf("operations must contain between 1 and %d steps", MaxOperations)
+	ErrUnknownOperation  = errors.New("unknown operation")
+	ErrInvalidArgument   = errors.New("invalid operation argument")
+)
+
+// Operation is a single step of a pipeline: the name of the operation and
+// its arguments, kept as strings so they can come from JSON documents,
+// query strings or URL paths alike.
+type Operation struct {
+	Name string
+	Args map[string]string
+}
+
+// step is a compiled operation, ready to be applied to an image using the
+// workers of a pool. Steps return early with the error of ctx once it is
+// done.
+type step func(ctx context.Context, pool *Pool, img image.Image) (image.Image, error)
+
+// ParseOperations decodes a pipeline in the form
+// [{"op": "gaussianblur"}, {"op": "custom", "kernel": [[0,-1,0],[-1,5,-1],[0,-1,0]]}]
+// and validates every step.
+func ParseOperations(raw string) ([]Operation, error) {
+	var steps []map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &steps); err != nil {
+		return nil, ErrPipelineMalformed
+	}
+
+	ops := make([]Operation, len(steps))
+	for i, fields := range steps {
+		var name string
+		if err := json.Unmarshal(fields["op"], &name); err != nil || name == "" {
+			return nil, ErrPipelineMalformed
+		}
+
+		args := map[string]string{}
+		for key, value := range fields {
+			if key == "op" {
+				continue
+			}
+			// Strings are unquoted while numbers and matrices are kept as
+			// their JSON text, which is what the argument parsers expect.
+			var s string
+			if err := json.Unmarshal(value, &s); err == nil {
+				args[key] = s
+			} else {
+				args[key] = string(value)
+			}
+		}
+
+		ops[i] = Operation{Name: strings.ToLower(name), Args: args}
+	}
+
+	if len(ops) == 0 {
+		return nil, ErrPipelineLength
+	}
+
+	if err := ValidateOperations(ops); err != nil {
+		return nil, err
+	}
+
+	return ops, nil
+}
+
+// ValidateOperations checks that every operation exists and is given valid
+// arguments.
+func ValidateOperations(ops []Operation) error {
+	_, err := compile(ops)
+	return err
+}
+
+func compile(ops []Operation) ([]step, error) {
+	if len(ops) > MaxOperations {
+		return nil, ErrPipelineLength
+	}
+
+	steps := make([]step, len(ops))
+	for i, op := range ops {
+		filter, ok := LookupFilter(op.Name)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownOperation, op.Name)
+		}
+
+		for arg := range op.Args {
+			if !filter.accepts(arg) {
+				return nil, fmt.Errorf("%w: %s does not accept %q", ErrInvalidArgument, op.Name, arg)
+			}
+		}
+
+		s, err := filter.compile(op.Args)
+		if err != nil {
+			return nil, err
+		}
+		steps[i] = s
+	}
+
+	return steps, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (sv *service) ApplyPipeline(ctx context.Context, img image.Image, ops []Operation, opts EncodeOptions) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	if sv.enabled != nil {
+		for _, op := range ops {
+			if !sv.enabled[op.Name] {
+				return nil, fmt.Errorf("%w: %q", ErrUnknownOperation, op.Name)
+			}
+		}
+	}
+
+	steps, err := compile(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pixels of img were reserved by the caller, and stay reserved
+	// along with those of larger images until the output is encoded.
+	ctx, release := withReservation(ctx, sv.budget, img.Bounds().Size())
+	defer release()
+
+	for _, s := range steps {
+		if img, err = s(ctx, sv.pool, img); err != nil {
+			return nil, err
+		}
+	}
+
+	return encodeContext(ctx, img, opts)
+}
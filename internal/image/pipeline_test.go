@@ -0,0 +1,84 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"strings"
+	"testing"
+
+	"github.com/drew138/go-graphics/filters/kernels"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseOperations(t *testing.T) {
+	ops, err := ParseOperations(`[{"op": "GaussianBlur"}, {"op": "custom", "kernel": [[0, -1, 0], [-1, 5, -1], [0, -1, 0]]}]`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []Operation{
+		{Name: "gaussianblur", Args: map[string]string{}},
+		{Name: "custom", Args: map[string]string{"kernel": "[[0, -1, 0], [-1, 5, -1], [0, -1, 0]]"}},
+	}, ops)
+}
+
+func TestParseOperations_Errors(t *testing.T) {
+	cases := map[string]error{
+		`{"op": "sharpen"}`:                      ErrPipelineMalformed,
+		`[{"kernel": [[1]]}]`:                    ErrPipelineMalformed,
+		`[{"op": 3}]`:                            ErrPipelineMalformed,
+		`[]`:                                     ErrPipelineLength,
+		`[{"op": "emboss"}]`:                     ErrUnknownOperation,
+		`[{"op": "sharpen", "amount": 2}]`:       ErrInvalidArgument,
+		`[{"op": "custom"}]`:                     ErrKernelMalformed,
+		`[{"op": "custom", "kernel": [[1, 2]]}]`: ErrKernelShape,
+	}
+
+	for raw, expected := range cases {
+		_, err := ParseOperations(raw)
+		assert.ErrorIs(t, err, expected, raw)
+	}
+
+	long := "[" + strings.Repeat(`{"op": "sharpen"},`, MaxOperations) + `{"op": "sharpen"}]`
+	_, err := ParseOperations(long)
+	assert.ErrorIs(t, err, ErrPipelineLength)
+}
+
+func TestApplyPipeline_MatchesChainedFilters(t *testing.T) {
+	img := gradient(32, 32)
+
+	out, err := NewService().ApplyPipeline(context.Background(), img, []Operation{{Name: "boxblur"}, {Name: "sharpen"}}, EncodeOptions{Format: FormatPNG})
+	assert.NoError(t, err)
+
+	blurred, err := convolve(kernels.BoxBlur, defaultEdges)(context.Background(), nil, img)
+	assert.NoError(t, err)
+	sharpened, err := convolve(kernels.Sharpen, defaultEdges)(context.Background(), nil, blurred)
+	assert.NoError(t, err)
+	expected, err := encodeContext(context.Background(), sharpened, EncodeOptions{Format: FormatPNG})
+	assert.NoError(t, err)
+
+	assert.True(t, bytes.Equal(expected, out))
+}
+
+func TestApplyPipeline_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewService().ApplyPipeline(ctx, gradient(32, 32), []Operation{{Name: "boxblur"}, {Name: "sharpen"}}, EncodeOptions{Format: FormatPNG})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestApplyPipeline_InvalidOperation(t *testing.T) {
+	_, err := NewService().ApplyPipeline(context.Background(), image.NewRGBA(image.Rect(0, 0, 4, 4)), []Operation{{Name: "emboss"}}, EncodeOptions{Format: FormatPNG})
+
+	assert.ErrorIs(t, err, ErrUnknownOperation)
+}
+
+func TestApplyPipeline_DisabledOperation(t *testing.T) {
+	sv := NewService(WithOperations("sharpen"))
+
+	_, err := sv.ApplyPipeline(context.Background(), gradient(8, 8), []Operation{{Name: "sharpen"}}, EncodeOptions{Format: FormatPNG})
+	assert.NoError(t, err)
+
+	_, err = sv.ApplyPipeline(context.Background(), gradient(8, 8), []Operation{{Name: "sharpen"}, {Name: "boxblur"}}, EncodeOptions{Format: FormatPNG})
+	assert.ErrorIs(t, err, ErrUnknownOperation)
+}
@@ -0,0 +1,145 @@
+package image
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// minBandHeight is the smallest number of rows handed to a worker at once,
+// below which scheduling overhead outweighs the work.
+const minBandHeight = 16
+
+// bandsPerWorker splits images in more bands than workers so that workers
+// finishing early can pick up the remaining ones.
+const bandsPerWorker = 4
+
+// saturationJobsPerWorker is the number of concurrent jobs per worker past
+// which a pool is considered saturated: every job then takes several times
+// longer than it would on an idle pool.
+const saturationJobsPerWorker = 4
+
+// Pool is a fixed set of goroutines shared by every request, which bounds
+// the CPU used for filtering regardless of the number of concurrent
+// requests. A nil *Pool runs work on the calling goroutine.
+type Pool struct {
+	workers int
+	tasks   chan func()
+	once    sync.Once
+
+	busy atomic.Int64
+	jobs atomic.Int64
+}
+
+// PoolStats is a snapshot of the activity of a pool.
+type PoolStats struct {
+	// Workers is the number of goroutines of the pool.
+	Workers int `json:"workers"`
+	// Busy is the number of workers running a task.
+	Busy int `json:"busy"`
+	// Jobs is the number of jobs, such as a convolution pass over an
+	// image, being run or waiting for workers.
+	Jobs int `json:"jobs"`
+}
+
+// Saturated reports whether there are so many jobs per worker that new
+// ones would be significantly delayed.
+func (s PoolStats) Saturated() bool {
+	return s.Jobs > s.Workers*saturationJobsPerWorker
+}
+
+// NewPool starts a pool of the given number of workers, or of GOMAXPROCS
+// workers if workers is not positive.
+func NewPool(workers int) *Pool {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	p := &Pool{workers: workers, tasks: make(chan func(), workers)}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for task := range p.tasks {
+				p.busy.Add(1)
+				task()
+				p.busy.Add(-1)
+			}
+		}()
+	}
+	return p
+}
+
+// Workers returns the number of goroutines of the pool.
+func (p *Pool) Workers() int {
+	if p == nil {
+		return 1
+	}
+	return p.workers
+}
+
+// Stats returns the current activity of the pool.
+func (p *Pool) Stats() PoolStats {
+	if p == nil {
+		return PoolStats{Workers: 1}
+	}
+	return PoolStats{
+		Workers: p.workers,
+		Busy:    int(p.busy.Load()),
+		Jobs:    int(p.jobs.Load()),
+	}
+}
+
+// Close stops the workers once the queued tasks are done. The pool must
+// not be used afterwards.
+func (p *Pool) Close() {
+	p.once.Do(func() {
+		close(p.tasks)
+	})
+}
+
+// run calls fn for every i in [0, n) on the workers of the pool and waits
+// for the calls to return. Once ctx is done, the calls that have not
+// started yet are skipped and its error is returned.
+func (p *Pool) run(ctx context.Context, n int, fn func(i int)) error {
+	if p == nil {
+		for i := 0; i < n && ctx.Err() == nil; i++ {
+			fn(i)
+		}
+		return ctx.Err()
+	}
+
+	p.jobs.Add(1)
+	defer p.jobs.Add(-1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		i := i
+		task := func() {
+			defer wg.Done()
+			if ctx.Err() == nil {
+				fn(i)
+			}
+		}
+
+		wg.Add(1)
+		select {
+		case p.tasks <- task:
+		case <-ctx.Done():
+			wg.Done()
+		}
+	}
+	wg.Wait()
+
+	return ctx.Err()
+}
+
+// rows splits [0, height) in bands of consecutive rows and calls fn for
+// each of them on the workers of the pool.
+func (p *Pool) rows(ctx context.Context, height int, fn func(y0, y1 int)) error {
+	bands := p.Workers() * bandsPerWorker
+	size := max((height+bands-1)/bands, minBandHeight)
+
+	return p.run(ctx, (height+size-1)/size, func(i int) {
+		fn(i*size, min((i+1)*size, height))
+	})
+}
@@ -0,0 +1,102 @@
+package image
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/drew138/go-graphics/filters/kernels"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool_Run(t *testing.T) {
+	pool := NewPool(4)
+	defer pool.Close()
+
+	var calls [100]atomic.Int32
+	pool.run(context.Background(), len(calls), func(i int) {
+		calls[i].Add(1)
+	})
+
+	for i := range calls {
+		assert.Equal(t, int32(1), calls[i].Load(), i)
+	}
+}
+
+func TestPool_Rows(t *testing.T) {
+	for _, pool := range []*Pool{nil, NewPool(3)} {
+		for _, height := range []int{1, 15, 16, 17, 1000} {
+			covered := make([]atomic.Int32, height)
+			pool.rows(context.Background(), height, func(y0, y1 int) {
+				for y := y0; y < y1; y++ {
+					covered[y].Add(1)
+				}
+			})
+
+			for y := range covered {
+				assert.Equal(t, int32(1), covered[y].Load(), "row %d of %d", y, height)
+			}
+		}
+	}
+}
+
+func TestPool_Stats(t *testing.T) {
+	pool := NewPool(1)
+	defer pool.Close()
+
+	assert.Equal(t, PoolStats{Workers: 1}, pool.Stats())
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		pool.run(context.Background(), 1, func(int) {
+			close(started)
+			<-release
+		})
+		close(done)
+	}()
+
+	<-started
+	assert.Equal(t, PoolStats{Workers: 1, Busy: 1, Jobs: 1}, pool.Stats())
+	close(release)
+	<-done
+
+	assert.Equal(t, 0, pool.Stats().Jobs)
+	assert.False(t, PoolStats{Workers: 2, Jobs: 8}.Saturated())
+	assert.True(t, PoolStats{Workers: 2, Jobs: 9}.Saturated())
+}
+
+func TestPool_DefaultsToGOMAXPROCS(t *testing.T) {
+	pool := NewPool(0)
+	defer pool.Close()
+
+	assert.Greater(t, pool.Workers(), 0)
+	assert.Equal(t, 1, (*Pool)(nil).Workers())
+}
+
+// Splitting the work must not change a single bit of the output, whatever
+// the number of workers and the algorithm.
+func TestParallelConvolution_BitIdentical(t *testing.T) {
+	img := noise(301, 203, 7)
+	algorithms := map[string]func(*Pool) []uint8{
+		"direct": func(p *Pool) []uint8 {
+			return must(convolveDirect(context.Background(), p, img, kernels.Sharpen, defaultEdges)).Pix
+		},
+		"separable": func(p *Pool) []uint8 {
+			weights := GaussianWeights(2, 6)
+			return must(convolveSeparable(context.Background(), p, img, weights, weights, defaultEdges)).Pix
+		},
+		"fft": func(p *Pool) []uint8 {
+			return must(convolveFFT(context.Background(), p, img, randomKernel(21, 7), defaultEdges)).Pix
+		},
+	}
+
+	for name, convolve := range algorithms {
+		serial := convolve(nil)
+		for _, workers := range []int{1, 2, 7} {
+			pool := NewPool(workers)
+			assert.Equal(t, serial, convolve(pool), "%s with %d workers", name, workers)
+			pool.Close()
+		}
+	}
+}
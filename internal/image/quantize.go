@@ -0,0 +1,135 @@
+package image
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// maxQuantizeSamples bounds the number of pixels the palette is built from,
+// which keeps the memory and time spent on large images constant.
+const maxQuantizeSamples = 1 << 16
+
+// medianCut is a draw.Quantizer that builds a palette adapted to the image
+// by repeatedly splitting the box of colours with the widest channel range
+// at its median. The standard library otherwise falls back to a prefix of
+// the Plan 9 palette, which looks poor for small palette sizes. The palette
+// is built from a regular sample of the pixels, and stops being refined
+// once ctx is done, the encoder then failing on its first write.
+type medianCut struct {
+	ctx context.Context
+}
+
+type colorBox []color.RGBA
+
+func (q medianCut) Quantize(p color.Palette, m image.Image) color.Palette {
+	size := cap(p) - len(p)
+	if size <= 0 {
+		return p
+	}
+
+	pixels := samples(m)
+	if len(pixels) == 0 {
+		return p
+	}
+
+	boxes := []colorBox{pixels}
+	for len(boxes) < size && q.ctx.Err() == nil {
+		widest, channel, spread := -1, 0, 0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if c, s := box.widestChannel(); s > spread {
+				widest, channel, spread = i, c, s
+			}
+		}
+		if widest < 0 {
+			break
+		}
+
+		box := boxes[widest]
+		sort.Slice(box, func(i, j int) bool {
+			return channelOf(box[i], channel) < channelOf(box[j], channel)
+		})
+		mid := len(box) / 2
+		boxes[widest] = box[:mid]
+		boxes = append(boxes, box[mid:])
+	}
+
+	for _, box := range boxes {
+		p = append(p, box.mean())
+	}
+	return p
+}
+
+// samples returns the pixels of m on a grid spaced so that there are at
+// most maxQuantizeSamples of them.
+func samples(m image.Image) colorBox {
+	bounds := m.Bounds()
+	step := 1
+	if n := bounds.Dx() * bounds.Dy(); n > maxQuantizeSamples {
+		step = int(math.Ceil(math.Sqrt(float64(n) / maxQuantizeSamples)))
+	}
+
+	pixels := make(colorBox, 0, ((bounds.Dx()+step-1)/step)*((bounds.Dy()+step-1)/step))
+	rgba, _ := m.(*image.RGBA)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			if rgba != nil {
+				px := rgba.Pix[rgba.PixOffset(x, y):]
+				pixels = append(pixels, color.RGBA{px[0], px[1], px[2], px[3]})
+				continue
+			}
+			pixels = append(pixels, color.RGBAModel.Convert(m.At(x, y)).(color.RGBA))
+		}
+	}
+	return pixels
+}
+
+func (b colorBox) widestChannel() (int, int) {
+	channel, spread := 0, -1
+	for c := 0; c < 4; c++ {
+		lo, hi := uint8(255), uint8(0)
+		for _, px := range b {
+			v := channelOf(px, c)
+			if v < lo {
+				lo = v
+			}
+			if v > hi {
+				hi = v
+			}
+		}
+		if int(hi)-int(lo) > spread {
+			channel, spread = c, int(hi)-int(lo)
+		}
+	}
+	return channel, spread
+}
+
+func (b colorBox) mean() color.Color {
+	var r, g, bl, a int
+	for _, px := range b {
+		r += int(px.R)
+		g += int(px.G)
+		bl += int(px.B)
+		a += int(px.A)
+	}
+	n := len(b)
+	return color.RGBA{uint8(r / n), uint8(g / n), uint8(bl / n), uint8(a / n)}
+}
+
+func channelOf(c color.RGBA, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	case 2:
+		return c.B
+	default:
+		return c.A
+	}
+}
@@ -0,0 +1,34 @@
+package image
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMedianCut_Samples(t *testing.T) {
+	assert.Len(t, samples(gradient(16, 16)), 256)
+
+	// Large images are sampled on a grid.
+	pixels := samples(gradient(1000, 700))
+	assert.LessOrEqual(t, len(pixels), maxQuantizeSamples)
+	assert.Greater(t, len(pixels), maxQuantizeSamples/2)
+	assert.Equal(t, color.RGBA{0, 0, 0, 255}, pixels[0])
+}
+
+func TestMedianCut_Quantize(t *testing.T) {
+	palette := medianCut{context.Background()}.Quantize(make(color.Palette, 0, 16), gradient(64, 64))
+	assert.Len(t, palette, 16)
+
+	// A canceled request gets a palette as coarse as it was when canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	palette = medianCut{ctx}.Quantize(make(color.Palette, 0, 16), gradient(64, 64))
+	assert.Len(t, palette, 1)
+
+	palette = medianCut{context.Background()}.Quantize(make(color.Palette, 0, 16), image.NewRGBA(image.Rect(0, 0, 0, 0)))
+	assert.Empty(t, palette)
+}
@@ -0,0 +1,63 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	"github.com/drew138/graphics-api/internal/admission"
+)
+
+// reservation holds the pixels a pipeline has reserved from the admission
+// budget. Its caller reserves the pixels of the input image before handing
+// it over, and steps enlarging the image reserve the difference before
+// allocating it, so that the budget bounds the largest image of the
+// pipeline rather than only its input.
+type reservation struct {
+	budget   *admission.Budget
+	pixels   int64
+	releases []func()
+}
+
+type reservationKey struct{}
+
+// withReservation returns a context under which steps reserve the pixels
+// of the images larger than one of size from budget, and a function giving
+// them back once the pipeline is done.
+func withReservation(ctx context.Context, budget *admission.Budget, size image.Point) (context.Context, func()) {
+	r := &reservation{budget: budget, pixels: int64(size.X) * int64(size.Y)}
+	return context.WithValue(ctx, reservationKey{}, r), func() {
+		for _, release := range r.releases {
+			release()
+		}
+	}
+}
+
+// reserve makes sure that the pipeline running under ctx holds enough
+// pixels for an image of the given size, acquiring the missing ones from
+// its budget. Images larger than the whole budget are rejected with
+// ErrInvalidArgument, and otherwise the error of admission.Budget.Acquire
+// is returned if the pixels could not be reserved in time. Outside of a
+// pipeline, reserving does nothing.
+func reserve(ctx context.Context, size image.Point) error {
+	r, ok := ctx.Value(reservationKey{}).(*reservation)
+	if !ok {
+		return nil
+	}
+
+	pixels := int64(size.X) * int64(size.Y)
+	if pixels <= r.pixels {
+		return nil
+	}
+	if capacity := r.budget.Capacity(); capacity > 0 && pixels > capacity {
+		return fmt.Errorf("%w: resulting image of %dx%d pixels exceeds the pixel budget of the server", ErrInvalidArgument, size.X, size.Y)
+	}
+
+	release, err := r.budget.Acquire(ctx, pixels-r.pixels)
+	if err != nil {
+		return err
+	}
+	r.pixels = pixels
+	r.releases = append(r.releases, release)
+	return nil
+}
@@ -0,0 +1,53 @@
+package image
+
+import (
+	"context"
+	"image"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/drew138/graphics-api/internal/admission"
+)
+
+func TestReserve(t *testing.T) {
+	budget := admission.NewBudget(100, 10*time.Millisecond)
+	input, _ := budget.Acquire(context.Background(), 20)
+	defer input()
+
+	ctx, release := withReservation(context.Background(), budget, image.Pt(5, 4))
+
+	// Images no larger than the input take nothing more.
+	assert.NoError(t, reserve(ctx, image.Pt(4, 5)))
+	assert.NoError(t, reserve(ctx, image.Pt(10, 5)))
+	assert.NoError(t, reserve(ctx, image.Pt(8, 8)))
+
+	// 20 pixels for the input and 44 reserved by the pipeline.
+	other, err := budget.Acquire(context.Background(), 37)
+	assert.ErrorIs(t, err, admission.ErrBudgetExhausted)
+	other, err = budget.Acquire(context.Background(), 36)
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, reserve(ctx, image.Pt(10, 10)), admission.ErrBudgetExhausted)
+	other()
+	release()
+
+	other, err = budget.Acquire(context.Background(), 80)
+	assert.NoError(t, err)
+	other()
+}
+
+func TestReserve_BeyondBudget(t *testing.T) {
+	budget := admission.NewBudget(100, time.Second)
+	ctx, release := withReservation(context.Background(), budget, image.Pt(5, 5))
+	defer release()
+
+	assert.ErrorIs(t, reserve(ctx, image.Pt(11, 10)), ErrInvalidArgument)
+
+	// Without a budget, and outside of pipelines, nothing is reserved.
+	ctx, release = withReservation(context.Background(), nil, image.Pt(5, 5))
+	defer release()
+	assert.NoError(t, reserve(ctx, image.Pt(1000, 1000)))
+	assert.NoError(t, reserve(context.Background(), image.Pt(1000, 1000)))
+}
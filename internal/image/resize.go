@@ -0,0 +1,405 @@
+package image
+
+import (
+	"context"
+	"image"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Resampling algorithms of the resize operation, from the fastest to the
+// sharpest.
+const (
+	ResampleNearest  = "nearest"
+	ResampleBilinear = "bilinear"
+	ResampleBicubic  = "bicubic"
+	ResampleLanczos3 = "lanczos3"
+)
+
+// Fit modes of the resize operation, telling how an image is fitted in a
+// box of the given width and height.
+const (
+	// FitCover scales the image to cover the box, keeping its aspect
+	// ratio, and crops the overflow on either side.
+	FitCover = "cover"
+	// FitContain scales the image to fit within the box, keeping its
+	// aspect ratio, and pads it with transparent pixels to the box.
+	FitContain = "contain"
+	// FitFill stretches the image to the box.
+	FitFill = "fill"
+	// FitInside scales the image to fit within the box, keeping its aspect
+	// ratio, without padding.
+	FitInside = "inside"
+)
+
+// MaxResizeScale bounds the scale factor of the resize operation.
+const MaxResizeScale = 16
+
+// resampler is the reconstruction filter of a resampling algorithm, which
+// weighs the source samples within support of an output sample.
+type resampler struct {
+	support float64
+	weight  func(t float64) float64
+}
+
+var resamplers = map[string]resampler{
+	// Nearest neighbour takes a single sample and needs no weights.
+	ResampleNearest: {},
+	ResampleBilinear: {1, func(t float64) float64 {
+		return 1 - math.Abs(t)
+	}},
+	ResampleBicubic:  {2, catmullRom},
+	ResampleLanczos3: {3, lanczos3},
+}
+
+// catmullRom is the cubic convolution kernel with a = -0.5.
+func catmullRom(t float64) float64 {
+	t = math.Abs(t)
+	if t < 1 {
+		return (1.5*t-2.5)*t*t + 1
+	}
+	return ((-0.5*t+2.5)*t-4)*t + 2
+}
+
+func lanczos3(t float64) float64 {
+	if t == 0 {
+		return 1
+	}
+	return sinc(t) * sinc(t/3)
+}
+
+func sinc(t float64) float64 {
+	t *= math.Pi
+	return math.Sin(t) / t
+}
+
+func init() {
+	register(Filter{
+		Name:        "resize",
+		Description: "Resizes the image to the given width and/or height, or by the given scale.",
+		Params: []Param{
+			{Name: "width", Type: ParamInteger, Description: "Target width in pixels. Derived from the height and the aspect ratio when not given.", Minimum: bound(1), Maximum: bound(MaxOutputSide)},
+			{Name: "height", Type: ParamInteger, Description: "Target height in pixels. Derived from the width and the aspect ratio when not given.", Minimum: bound(1), Maximum: bound(MaxOutputSide)},
+			{Name: "fit", Type: ParamString, Description: "How the image is fitted in the box given by both the width and the height.", Enum: []string{FitCover, FitContain, FitFill, FitInside}, Default: FitCover},
+			{Name: "resample", Type: ParamString, Description: "Resampling algorithm.", Enum: []string{ResampleNearest, ResampleBilinear, ResampleBicubic, ResampleLanczos3}, Default: ResampleLanczos3},
+			{Name: "scale", Type: ParamNumber, Description: "Scale factor, instead of a width and a height.", Minimum: bound(0), Maximum: bound(MaxResizeScale)},
+			{Name: "upscale", Type: ParamBoolean, Description: "Whether the image may be enlarged.", Default: true},
+		},
+		compile: compileResize,
+	})
+}
+
+// resize is a compiled resize operation.
+type resize struct {
+	width, height int
+	scale         float64
+	fit           string
+	resampler     resampler
+	upscale       bool
+}
+
+func compileResize(args map[string]string) (step, error) {
+	rs, err := parseResize(args)
+	if err != nil {
+		return nil, err
+	}
+	return rs.apply, nil
+}
+
+func parseResize(args map[string]string) (resize, error) {
+	// Empty arguments stand for the ones skipped in transformation paths,
+	// e.g. resize::200 for a height alone.
+	arg := func(name string) (string, bool) {
+		value := args[name]
+		return value, value != ""
+	}
+
+	rs := resize{fit: FitCover, resampler: resamplers[ResampleLanczos3], upscale: true}
+	var err error
+
+	for _, side := range []struct {
+		name  string
+		value *int
+	}{{"width", &rs.width}, {"height", &rs.height}} {
+		if raw, ok := arg(side.name); ok {
+			if *side.value, err = strconv.Atoi(raw); err != nil || *side.value < 1 || *side.value > MaxOutputSide {
+				return resize{}, invalidArgument("%s must be an integer between 1 and %d", side.name, MaxOutputSide)
+			}
+		}
+	}
+	if raw, ok := arg("scale"); ok {
+		if rs.scale, err = strconv.ParseFloat(raw, 64); err != nil || !(rs.scale > 0 && rs.scale <= MaxResizeScale) {
+			return resize{}, invalidArgument("scale must be a number above 0 and up to %d", MaxResizeScale)
+		}
+	}
+	if raw, ok := arg("fit"); ok {
+		switch rs.fit = strings.ToLower(raw); rs.fit {
+		case FitCover, FitContain, FitFill, FitInside:
+		default:
+			return resize{}, invalidArgument("fit must be one of %s, %s, %s or %s", FitCover, FitContain, FitFill, FitInside)
+		}
+	}
+	if raw, ok := arg("resample"); ok {
+		var known bool
+		if rs.resampler, known = resamplers[strings.ToLower(raw)]; !known {
+			return resize{}, invalidArgument("resample must be one of %s, %s, %s or %s", ResampleNearest, ResampleBilinear, ResampleBicubic, ResampleLanczos3)
+		}
+	}
+	if raw, ok := arg("upscale"); ok {
+		if rs.upscale, err = strconv.ParseBool(raw); err != nil {
+			return resize{}, invalidArgument("upscale must be true or false")
+		}
+	}
+
+	switch {
+	case rs.scale == 0 && rs.width == 0 && rs.height == 0:
+		return resize{}, invalidArgument("resize requires a width, a height or a scale")
+	case rs.scale != 0 && (rs.width != 0 || rs.height != 0):
+		return resize{}, invalidArgument("resize accepts either a scale or a width and a height")
+	}
+
+	return rs, nil
+}
+
+// layout is where a resized image goes: the source is scaled by sx and sy,
+// the window of the scaled image starting at offset is kept, and placed at
+// origin in a canvas of the given size.
+type layout struct {
+	canvas  image.Point
+	window  image.Point
+	sx, sy  float64
+	offset  image.Point
+	origin  image.Point
+	padding bool
+}
+
+// layout computes the layout of the resized image of a w x h source.
+func (rs resize) layout(w, h int) layout {
+	sw, sh := float64(w), float64(h)
+	limit := func(s float64) float64 {
+		if !rs.upscale {
+			return min(s, 1)
+		}
+		return s
+	}
+	size := func(n int, s float64) int {
+		return max(int(math.Round(float64(n)*s)), 1)
+	}
+
+	// A single side, or a scale, keeps the aspect ratio.
+	var sx, sy float64
+	switch {
+	case rs.scale != 0:
+		sx = limit(rs.scale)
+		sy = sx
+	case rs.height == 0:
+		sx = limit(float64(rs.width) / sw)
+		sy = sx
+	case rs.width == 0:
+		sy = limit(float64(rs.height) / sh)
+		sx = sy
+	}
+	if sx != 0 {
+		dw, dh := size(w, sx), size(h, sy)
+		return layout{canvas: image.Pt(dw, dh), window: image.Pt(dw, dh), sx: float64(dw) / sw, sy: float64(dh) / sh}
+	}
+
+	bw, bh := rs.width, rs.height
+	switch rs.fit {
+	case FitFill:
+		sx, sy = limit(float64(bw)/sw), limit(float64(bh)/sh)
+		dw, dh := size(w, sx), size(h, sy)
+		return layout{canvas: image.Pt(dw, dh), window: image.Pt(dw, dh), sx: float64(dw) / sw, sy: float64(dh) / sh}
+	case FitCover:
+		s := limit(max(float64(bw)/sw, float64(bh)/sh))
+		dw, dh := size(w, s), size(h, s)
+		window := image.Pt(min(bw, dw), min(bh, dh))
+		return layout{
+			canvas: window,
+			window: window,
+			sx:     float64(dw) / sw,
+			sy:     float64(dh) / sh,
+			offset: image.Pt((dw-window.X)/2, (dh-window.Y)/2),
+		}
+	default:
+		s := limit(min(float64(bw)/sw, float64(bh)/sh))
+		dw, dh := min(size(w, s), bw), min(size(h, s), bh)
+		l := layout{canvas: image.Pt(dw, dh), window: image.Pt(dw, dh), sx: float64(dw) / sw, sy: float64(dh) / sh}
+		if rs.fit == FitContain {
+			l.canvas = image.Pt(bw, bh)
+			l.origin = image.Pt((bw-dw)/2, (bh-dh)/2)
+			l.padding = true
+		}
+		return l
+	}
+}
+
+func (rs resize) apply(ctx context.Context, pool *Pool, img image.Image) (image.Image, error) {
+	src := toRGBA(img)
+	bounds := src.Bounds()
+	l := rs.layout(bounds.Dx(), bounds.Dy())
+
+	if err := checkOutput(l.canvas); err != nil {
+		return nil, err
+	}
+	if err := reserve(ctx, l.canvas); err != nil {
+		return nil, err
+	}
+
+	resized, err := resample(ctx, pool, src, l.window, l.sx, l.sy, l.offset, rs.resampler)
+	if err != nil || !l.padding {
+		return resized, err
+	}
+
+	canvas := image.NewRGBA(image.Rectangle{Max: l.canvas})
+	draw.Draw(canvas, resized.Bounds().Add(l.origin), resized, image.Point{}, draw.Src)
+	return canvas, nil
+}
+
+// contribution is the weighted sum of consecutive source samples an output
+// sample is resampled from.
+type contribution struct {
+	first   int
+	weights []float32
+}
+
+// contributions computes the contributions of n output samples taken from
+// a line of src samples scaled by scale, the first output sample standing
+// at offset in the scaled line. Downscaling stretches the filter so that
+// every source sample contributes, and taps beyond the edges are dropped,
+// renormalizing the others.
+func contributions(src, n int, scale float64, offset int, r resampler) []contribution {
+	stretch := max(1/scale, 1)
+	support := r.support * stretch
+
+	out := make([]contribution, n)
+	for i := range out {
+		center := (float64(i+offset)+0.5)/scale - 0.5
+		if r.weight == nil {
+			out[i] = contribution{clamp(int(math.Floor(center+0.5)), src), []float32{1}}
+			continue
+		}
+
+		lo := max(int(math.Ceil(center-support)), 0)
+		hi := min(int(math.Floor(center+support)), src-1)
+		weights := make([]float64, 0, hi-lo+1)
+		var sum float64
+		for j := lo; j <= hi; j++ {
+			w := 0.0
+			if t := (float64(j) - center) / stretch; math.Abs(t) < r.support {
+				w = r.weight(t)
+			}
+			weights = append(weights, w)
+			sum += w
+		}
+		if sum == 0 {
+			out[i] = contribution{clamp(int(math.Floor(center+0.5)), src), []float32{1}}
+			continue
+		}
+
+		c := contribution{first: lo, weights: make([]float32, len(weights))}
+		for j, w := range weights {
+			c.weights[j] = float32(w / sum)
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// span returns the range of source samples read by cs.
+func span(cs []contribution) (int, int) {
+	lo, hi := cs[0].first, 0
+	for _, c := range cs {
+		lo = min(lo, c.first)
+		hi = max(hi, c.first+len(c.weights))
+	}
+	return lo, hi
+}
+
+// weigh sums the 4 channel samples of pix starting at o, step elements
+// apart, weighted by weights.
+func weigh[T uint8 | float32](pix []T, o, step int, weights []float32) (r, g, b, a float32) {
+	for _, w := range weights {
+		r += float32(pix[o]) * w
+		g += float32(pix[o+1]) * w
+		b += float32(pix[o+2]) * w
+		a += float32(pix[o+3]) * w
+		o += step
+	}
+	return r, g, b, a
+}
+
+// resample scales src by sx and sy and returns the window of the given
+// size starting at offset in the scaled image. Rows and columns are
+// resampled in two passes, in the order keeping the intermediate image
+// smallest. The channels are resampled premultiplied, so that transparent
+// pixels do not bleed their color.
+func resample(ctx context.Context, pool *Pool, src *image.RGBA, window image.Point, sx, sy float64, offset image.Point, r resampler) (*image.RGBA, error) {
+	bounds := src.Bounds()
+	xs := contributions(bounds.Dx(), window.X, sx, offset.X, r)
+	ys := contributions(bounds.Dy(), window.Y, sy, offset.Y, r)
+
+	// Only the source rows, or columns, the second pass reads are
+	// resampled by the first one.
+	top, bottom := span(ys)
+	left, right := span(xs)
+	rowsFirst := (bottom-top)*window.X <= (right-left)*window.Y
+
+	// tmp is a width x height image of 4 float channels.
+	var width, height int
+	if rowsFirst {
+		width, height = window.X, bottom-top
+	} else {
+		width, height = right-left, window.Y
+	}
+	tmp := make([]float32, width*height*4)
+
+	err := pool.rows(ctx, height, func(y0, y1 int) {
+		for y := y0; y < y1 && ctx.Err() == nil; y++ {
+			for x := 0; x < width; x++ {
+				var r, g, b, a float32
+				if rowsFirst {
+					c := xs[x]
+					r, g, b, a = weigh(src.Pix, (y+top)*src.Stride+c.first*4, 4, c.weights)
+				} else {
+					c := ys[y]
+					r, g, b, a = weigh(src.Pix, c.first*src.Stride+(x+left)*4, src.Stride, c.weights)
+				}
+				o := (y*width + x) * 4
+				tmp[o], tmp[o+1], tmp[o+2], tmp[o+3] = r, g, b, a
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	dst := image.NewRGBA(image.Rectangle{Max: window})
+	err = pool.rows(ctx, window.Y, func(y0, y1 int) {
+		for y := y0; y < y1 && ctx.Err() == nil; y++ {
+			for x := 0; x < window.X; x++ {
+				var r, g, b, a float32
+				if rowsFirst {
+					c := ys[y]
+					r, g, b, a = weigh(tmp, ((c.first-top)*width+x)*4, width*4, c.weights)
+				} else {
+					c := xs[x]
+					r, g, b, a = weigh(tmp, (y*width+c.first-left)*4, 4, c.weights)
+				}
+				o := y*dst.Stride + x*4
+				alpha := toChannel(a, 255)
+				dst.Pix[o] = toChannel(r, alpha)
+				dst.Pix[o+1] = toChannel(g, alpha)
+				dst.Pix[o+2] = toChannel(b, alpha)
+				dst.Pix[o+3] = alpha
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,161 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/drew138/graphics-api/internal/admission"
+)
+
+func compileResizeStep(t *testing.T, args map[string]string) step {
+	s, err := compileResize(args)
+	assert.NoError(t, err)
+	return s
+}
+
+func TestResize_Layout(t *testing.T) {
+	cases := []struct {
+		args   map[string]string
+		canvas image.Point
+		window image.Point
+		offset image.Point
+		origin image.Point
+	}{
+		{map[string]string{"width": "50"}, image.Pt(50, 25), image.Pt(50, 25), image.Point{}, image.Point{}},
+		{map[string]string{"height": "10"}, image.Pt(20, 10), image.Pt(20, 10), image.Point{}, image.Point{}},
+		{map[string]string{"scale": "1.5"}, image.Pt(150, 75), image.Pt(150, 75), image.Point{}, image.Point{}},
+		{map[string]string{"width": "40", "height": "40"}, image.Pt(40, 40), image.Pt(40, 40), image.Pt(20, 0), image.Point{}},
+		{map[string]string{"width": "40", "height": "40", "fit": "fill"}, image.Pt(40, 40), image.Pt(40, 40), image.Point{}, image.Point{}},
+		{map[string]string{"width": "40", "height": "40", "fit": "inside"}, image.Pt(40, 20), image.Pt(40, 20), image.Point{}, image.Point{}},
+		{map[string]string{"width": "40", "height": "40", "fit": "contain"}, image.Pt(40, 40), image.Pt(40, 20), image.Point{}, image.Pt(0, 10)},
+		{map[string]string{"width": "400", "upscale": "false"}, image.Pt(100, 50), image.Pt(100, 50), image.Point{}, image.Point{}},
+		{map[string]string{"width": "400", "height": "400", "upscale": "false"}, image.Pt(100, 50), image.Pt(100, 50), image.Point{}, image.Point{}},
+	}
+
+	for _, tc := range cases {
+		rs, err := parseResize(tc.args)
+		assert.NoError(t, err)
+
+		l := rs.layout(100, 50)
+		assert.Equal(t, tc.canvas, l.canvas, tc.args)
+		assert.Equal(t, tc.window, l.window, tc.args)
+		assert.Equal(t, tc.offset, l.offset, tc.args)
+		assert.Equal(t, tc.origin, l.origin, tc.args)
+
+		out, err := rs.apply(context.Background(), nil, gradient(100, 50))
+		assert.NoError(t, err)
+		assert.Equal(t, tc.canvas, out.Bounds().Size(), tc.args)
+	}
+}
+
+func TestResize_Identity(t *testing.T) {
+	src := gradient(17, 9)
+	for _, resample := range []string{ResampleNearest, ResampleBilinear, ResampleBicubic, ResampleLanczos3} {
+		out, err := compileResizeStep(t, map[string]string{"scale": "1", "resample": resample})(context.Background(), NewPool(2), src)
+
+		assert.NoError(t, err)
+		assert.Equal(t, src.Pix, out.(*image.RGBA).Pix, resample)
+	}
+}
+
+// Resampling must neither darken nor brighten flat areas, whatever the
+// algorithm and the direction.
+func TestResize_PreservesFlatColor(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 31, 23))
+	fill := color.RGBA{60, 120, 30, 128}
+	for i := 0; i < len(src.Pix); i += 4 {
+		src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3] = fill.R, fill.G, fill.B, fill.A
+	}
+
+	for _, resample := range []string{ResampleNearest, ResampleBilinear, ResampleBicubic, ResampleLanczos3} {
+		for _, size := range [][2]string{{"7", "5"}, {"80", "61"}} {
+			args := map[string]string{"width": size[0], "height": size[1], "fit": FitFill, "resample": resample}
+			out, err := compileResizeStep(t, args)(context.Background(), nil, src)
+			assert.NoError(t, err)
+
+			rgba := out.(*image.RGBA)
+			for i := 0; i < len(rgba.Pix); i += 4 {
+				if got := (color.RGBA{rgba.Pix[i], rgba.Pix[i+1], rgba.Pix[i+2], rgba.Pix[i+3]}); got != fill {
+					t.Fatalf("%v: expected %v, got %v", args, fill, got)
+				}
+			}
+		}
+	}
+}
+
+func TestResize_ContainPadsTransparent(t *testing.T) {
+	out, err := compileResizeStep(t, map[string]string{"width": "40", "height": "40", "fit": FitContain})(context.Background(), nil, gradient(100, 50))
+
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA{}, out.At(20, 5))
+	assert.Equal(t, uint8(255), out.(*image.RGBA).RGBAAt(20, 20).A)
+}
+
+func TestResize_Errors(t *testing.T) {
+	cases := []map[string]string{
+		{},
+		{"width": "0"},
+		{"height": "100000"},
+		{"scale": "-1"},
+		{"scale": "2", "width": "10"},
+		{"width": "10", "fit": "stretch"},
+		{"width": "10", "resample": "box"},
+		{"width": "10", "upscale": "maybe"},
+	}
+	for _, args := range cases {
+		_, err := compileResize(args)
+		assert.ErrorIs(t, err, ErrInvalidArgument, args)
+	}
+
+	// Upscaling beyond the maximum size is rejected before allocating.
+	_, err := compileResizeStep(t, map[string]string{"scale": "16"})(context.Background(), nil, image.NewRGBA(image.Rect(0, 0, 2000, 2000)))
+	assert.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestResize_Budget(t *testing.T) {
+	budget := admission.NewBudget(10_000, 10*time.Millisecond)
+	service := NewService(WithBudget(budget))
+	apply := func(args map[string]string) error {
+		_, err := service.ApplyPipeline(context.Background(), gradient(10, 10), []Operation{{Name: "resize", Args: args}}, EncodeOptions{Format: FormatPNG})
+		return err
+	}
+
+	assert.NoError(t, apply(map[string]string{"scale": "10"}))
+
+	// Tiny inputs cannot be enlarged beyond the whole budget.
+	assert.ErrorIs(t, apply(map[string]string{"scale": "16"}), ErrInvalidArgument)
+	assert.ErrorIs(t, apply(map[string]string{"width": "2000"}), ErrInvalidArgument)
+
+	// Nor beyond what is left of it.
+	release, _ := budget.Acquire(context.Background(), 5_000)
+	defer release()
+	assert.ErrorIs(t, apply(map[string]string{"scale": "10"}), admission.ErrBudgetExhausted)
+	assert.NoError(t, apply(map[string]string{"scale": "5"}))
+}
+
+func TestResize_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := compileResizeStep(t, map[string]string{"width": "64"})(ctx, NewPool(2), gradient(128, 128))
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestResize_Pipeline(t *testing.T) {
+	transformation, err := ParsePath("resize:32::inside:bilinear/sharpen/cat.png")
+	assert.NoError(t, err)
+	assert.Equal(t, "resize:32::inside:bilinear/sharpen/cat.png", transformation.String())
+
+	out, err := NewService().ApplyPipeline(context.Background(), gradient(64, 48), transformation.Operations, EncodeOptions{Format: FormatPNG})
+	assert.NoError(t, err)
+
+	img, _, err := Decode(bytes.NewReader(out), Limits{})
+	assert.NoError(t, err)
+	assert.Equal(t, image.Pt(32, 24), img.Bounds().Size())
+}
@@ -1,32 +1,70 @@
 package image
 
 import (
-	"bytes"
+	"context"
 	"image"
-	"image/jpeg"
 
-	"github.com/drew138/go-graphics/filters"
-	"github.com/drew138/go-graphics/filters/kernels"
+	"github.com/drew138/graphics-api/internal/admission"
 )
 
 type Service interface {
-	TransformImage(image image.Image, kernel kernels.Kernel) ([]byte, error)
+	ApplyPipeline(ctx context.Context, image image.Image, ops []Operation, opts EncodeOptions) ([]byte, error)
 }
 
-type service struct{}
+type service struct {
+	workers int
+	enabled map[string]bool
+	pool    *Pool
+	budget  *admission.Budget
+}
+
+// Option customizes the service built by NewService.
+type Option func(*service)
 
-func NewService() Service {
-	return &service{}
+// WithWorkers sets the number of goroutines filters run on. By default
+// there are as many as GOMAXPROCS.
+func WithWorkers(n int) Option {
+	return func(sv *service) {
+		sv.workers = n
+	}
 }
 
-func (sv *service) TransformImage(image image.Image, kernel kernels.Kernel) ([]byte, error) {
-	img := filters.ApplyFilter(image, kernel)
+// WithPool runs the filters on an existing pool, which takes precedence
+// over WithWorkers.
+func WithPool(pool *Pool) Option {
+	return func(sv *service) {
+		sv.pool = pool
+	}
+}
 
-	var buf bytes.Buffer
-	err := jpeg.Encode(&buf, img, nil)
-	if err != nil {
-		return nil, err
+// WithBudget makes the pipelines reserve the pixels of the images larger
+// than their input from budget, whose pixels the callers reserve before
+// decoding the input. By default outputs are only bounded by
+// MaxOutputPixels.
+func WithBudget(budget *admission.Budget) Option {
+	return func(sv *service) {
+		sv.budget = budget
 	}
+}
 
-	return buf.Bytes(), nil
+// WithOperations restricts the operations pipelines may use to names. By
+// default every operation is available.
+func WithOperations(names ...string) Option {
+	return func(sv *service) {
+		sv.enabled = map[string]bool{}
+		for _, name := range names {
+			sv.enabled[name] = true
+		}
+	}
+}
+
+func NewService(opts ...Option) Service {
+	sv := &service{}
+	for _, opt := range opts {
+		opt(sv)
+	}
+	if sv.pool == nil {
+		sv.pool = NewPool(sv.workers)
+	}
+	return sv
 }
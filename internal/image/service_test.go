@@ -1 +1,68 @@
 package image
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyPipeline_EncodesRequestedFormat(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	img.Set(4, 4, color.RGBA{255, 0, 0, 255})
+
+	for _, format := range []string{FormatJPEG, FormatPNG, FormatGIF, FormatBMP} {
+		out, err := NewService().ApplyPipeline(context.Background(), img, []Operation{{Name: "boxblur"}}, EncodeOptions{Format: format})
+		assert.NoError(t, err, format)
+
+		_, decoded, err := image.Decode(bytes.NewReader(out))
+		assert.NoError(t, err, format)
+		assert.Equal(t, format, decoded)
+	}
+}
+
+func TestApplyPipeline_UnsupportedFormat(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+
+	_, err := NewService().ApplyPipeline(context.Background(), img, []Operation{{Name: "boxblur"}}, EncodeOptions{Format: "webp"})
+
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestFormatFromContentType(t *testing.T) {
+	format, ok := FormatFromContentType("image/png; charset=binary")
+	assert.True(t, ok)
+	assert.Equal(t, FormatPNG, format)
+
+	format, ok = FormatFromContentType("image/x-ms-bmp")
+	assert.True(t, ok)
+	assert.Equal(t, FormatBMP, format)
+
+	_, ok = FormatFromContentType("text/plain")
+	assert.False(t, ok)
+}
+
+func TestRegisterEncoder(t *testing.T) {
+	RegisterEncoder("raw", "application/x-raw-rgba", func(w io.Writer, img image.Image, _ EncodeOptions) error {
+		_, err := w.Write(img.(*image.RGBA).Pix)
+		return err
+	})
+	defer func() {
+		encodersMu.Lock()
+		delete(encoders, "raw")
+		encodersMu.Unlock()
+	}()
+
+	format, ok := FormatFromMediaType("application/x-raw-rgba")
+	assert.True(t, ok)
+	assert.Equal(t, "raw", format)
+	assert.Contains(t, Formats(), "raw")
+
+	out, err := NewService().ApplyPipeline(context.Background(), image.NewRGBA(image.Rect(0, 0, 2, 2)), []Operation{{Name: "boxblur"}}, EncodeOptions{Format: "raw"})
+	assert.NoError(t, err)
+	assert.Len(t, out, 16)
+}
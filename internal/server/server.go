@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultShutdownDelay is how long the server keeps serving once reported
+// as not ready unless configured otherwise, which leaves load balancers
+// the time to notice and stop sending traffic. Along with
+// DefaultDrainTimeout, it stays below the 10 seconds Cloud Run waits
+// between SIGTERM and SIGKILL.
+const DefaultShutdownDelay = 2 * time.Second
+
+// DefaultDrainTimeout bounds the time in-flight requests are given to
+// complete on shutdown unless configured otherwise.
+const DefaultDrainTimeout = 7 * time.Second
+
+// Defaults of the timeouts of the connections, which keep slow or idle
+// clients from holding on to them. Reading a request includes its body,
+// which may be a large upload.
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = time.Minute
+	DefaultIdleTimeout       = 2 * time.Minute
+)
+
+var ErrDrainTimeout = errors.New("in-flight requests did not complete within the drain timeout")
+
+// Readiness reports whether the server should be sent new requests. The
+// zero value is ready.
+type Readiness struct {
+	draining atomic.Bool
+}
+
+// Ready returns false once the server has started shutting down.
+func (r *Readiness) Ready() bool {
+	return !r.draining.Load()
+}
+
+// Serve serves srv on l until ctx is done. It then reports the server as
+// not ready while still serving for delay, so that readiness probes see it
+// going away, stops accepting connections and waits at most drain for the
+// in-flight requests to complete, after which their contexts are canceled
+// so that the filters still running stop early, and ErrDrainTimeout is
+// returned.
+func Serve(ctx context.Context, srv *http.Server, l net.Listener, readiness *Readiness, delay, drain time.Duration) error {
+	base, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	srv.BaseContext = func(net.Listener) context.Context {
+		return base
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	readiness.draining.Store(true)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case err := <-errs:
+		return err
+	case <-timer.C:
+	}
+
+	drainCtx, stop := context.WithTimeout(context.Background(), drain)
+	defer stop()
+
+	err := srv.Shutdown(drainCtx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		// Canceling the requests makes their handlers respond and return,
+		// which Close does not wait for.
+		cancel()
+		err = errors.Join(ErrDrainTimeout, srv.Close())
+	}
+	if serveErr := <-errs; !errors.Is(serveErr, http.ErrServerClosed) {
+		return serveErr
+	}
+	return err
+}
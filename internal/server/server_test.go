@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// start serves handler until the returned cancel function is called, and
+// returns the base URL of the server and the result of Serve.
+func start(t *testing.T, handler http.Handler, readiness *Readiness, delay, drain time.Duration) (string, context.CancelFunc, <-chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, &http.Server{Handler: handler}, l, readiness, delay, drain)
+	}()
+	return "http://" + l.Addr().String(), cancel, done
+}
+
+func TestServe_DrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		_, _ = io.WriteString(w, "done")
+	})
+
+	readiness := &Readiness{}
+	url, cancel, done := start(t, handler, readiness, 0, time.Second)
+
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+
+	<-started
+	assert.True(t, readiness.Ready())
+	cancel()
+
+	assert.Equal(t, "done", <-responses)
+	assert.NoError(t, <-done)
+	assert.False(t, readiness.Ready())
+
+	// New connections are refused once shut down.
+	_, err := http.Get(url)
+	assert.Error(t, err)
+}
+
+func TestServe_DrainTimeout(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(canceled)
+	})
+
+	url, cancel, done := start(t, handler, &Readiness{}, 0, 10*time.Millisecond)
+	go func() {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	<-started
+	cancel()
+
+	assert.ErrorIs(t, <-done, ErrDrainTimeout)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the request context to be canceled")
+	}
+}
+
+func TestServe_ShutdownDelay(t *testing.T) {
+	readiness := &Readiness{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !readiness.Ready() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	url, cancel, done := start(t, handler, readiness, 200*time.Millisecond, time.Second)
+	status := func() int {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, status())
+	cancel()
+
+	// Probes still reach the server, which reports itself as not ready,
+	// until the delay is over.
+	assert.Eventually(t, func() bool { return !readiness.Ready() }, time.Second, time.Millisecond)
+	assert.Equal(t, http.StatusServiceUnavailable, status())
+	select {
+	case <-done:
+		t.Fatal("expected the server to keep serving during the delay")
+	default:
+	}
+
+	assert.NoError(t, <-done)
+	_, err := http.Get(url)
+	assert.Error(t, err)
+}
@@ -0,0 +1,78 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+var (
+	ErrNotFound  = errors.New("source image not found")
+	ErrInvalidID = errors.New("invalid source image identifier")
+)
+
+// validID restricts identifiers to a single path element so they cannot
+// escape the store.
+var validID = regexp.MustCompile(`^[A-Za-z0-9_-][A-Za-z0-9._-]*$`)
+
+// Store gives access to the source images transformations are applied to.
+type Store interface {
+	Open(ctx context.Context, id string) (io.ReadCloser, error)
+	// Ping checks that the images of the store can be reached.
+	Ping(ctx context.Context) error
+}
+
+type dir struct {
+	root string
+}
+
+// NewDir returns a Store serving the files of a local directory, using
+// their names as identifiers.
+func NewDir(root string) Store {
+	return &dir{root: root}
+}
+
+func (d *dir) Open(ctx context.Context, id string) (io.ReadCloser, error) {
+	if !validID.MatchString(id) {
+		return nil, ErrInvalidID
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(d.root, id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		file.Close()
+		return nil, ErrNotFound
+	}
+
+	return file, nil
+}
+
+func (d *dir) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(d.root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", d.root)
+	}
+	return nil
+}
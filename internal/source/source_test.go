@@ -0,0 +1,47 @@
+package source
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDir_Open(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "cat.png"), []byte("data"), 0o644))
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "nested"), 0o755))
+
+	store := NewDir(root)
+
+	file, err := store.Open(context.Background(), "cat.png")
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(file)
+	file.Close()
+	assert.Equal(t, "data", string(data))
+
+	_, err = store.Open(context.Background(), "dog.png")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = store.Open(context.Background(), "nested")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	for _, id := range []string{"", "..", ".hidden", "../cat.png", "a/b"} {
+		_, err = store.Open(context.Background(), id)
+		assert.ErrorIs(t, err, ErrInvalidID, id)
+	}
+}
+
+func TestDir_Ping(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, NewDir(root).Ping(context.Background()))
+
+	assert.Error(t, NewDir(filepath.Join(root, "missing")).Ping(context.Background()))
+
+	file := filepath.Join(root, "cat.png")
+	assert.NoError(t, os.WriteFile(file, []byte("data"), 0o644))
+	assert.Error(t, NewDir(file).Ping(context.Background()))
+}